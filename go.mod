@@ -8,6 +8,8 @@ require (
 	github.com/charmbracelet/log v0.4.2
 	github.com/getlantern/systray v1.2.2
 	github.com/joho/godotenv v1.5.1
+	go.etcd.io/bbolt v1.4.0
+	golang.org/x/net v0.35.0
 )
 
 require (
@@ -37,7 +39,7 @@ require (
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
-	golang.org/x/crypto v0.25.0 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
 	golang.org/x/exp v0.0.0-20231006140011-7918f672742d // indirect
 	golang.org/x/sys v0.30.0 // indirect
 )
@@ -0,0 +1,327 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/bwmarrin/discordgo"
+)
+
+// imageMediaTypes maps file extensions to the image media types Claude accepts
+var imageMediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// codeLanguages maps source file extensions to the language tag used for fenced code blocks
+var codeLanguages = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "jsx",
+	".ts":    "typescript",
+	".tsx":   "tsx",
+	".rs":    "rust",
+	".java":  "java",
+	".kt":    "kotlin",
+	".swift": "swift",
+	".c":     "c",
+	".h":     "c",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".rb":    "ruby",
+	".php":   "php",
+	".lua":   "lua",
+	".sh":    "bash",
+	".ps1":   "powershell",
+	".sql":   "sql",
+	".html":  "html",
+	".css":   "css",
+	".json":  "json",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+	".toml":  "toml",
+	".xml":   "xml",
+	".diff":  "diff",
+	".patch": "diff",
+}
+
+// textExtensions lists extensions of plain text files that are passed as text documents
+var textExtensions = map[string]bool{
+	".txt": true,
+	".log": true,
+	".md":  true,
+	".csv": true,
+	".tsv": true,
+	".ini": true,
+	".cfg": true,
+}
+
+// attachmentKind classifies attachments by how they are passed to Claude
+type attachmentKind int
+
+const (
+	attachmentUnsupported attachmentKind = iota
+	attachmentImage
+	attachmentPDF
+	attachmentCode
+	attachmentText
+)
+
+// attachmentBlocks converts a message's attachments into content blocks. Attachments
+// that are skipped get a short text notice so Claude knows they were there.
+func (s *Service) attachmentBlocks(ctx context.Context, msg *discordgo.Message) []anthropic.ContentBlockParamUnion {
+	var blocks []anthropic.ContentBlockParamUnion
+	images := 0
+
+	for _, attachment := range msg.Attachments {
+		var block anthropic.ContentBlockParamUnion
+		var err error
+
+		switch kind, mediaType := classifyAttachment(attachment); kind {
+		case attachmentImage:
+			if images >= s.maxImages {
+				blocks = append(blocks, attachmentNotice(attachment, fmt.Sprintf("only %d images are read per message", s.maxImages)))
+				continue
+			}
+			block, err = s.imageBlock(ctx, attachment, mediaType)
+			if err == nil {
+				images++
+			}
+		case attachmentPDF:
+			block, err = s.pdfBlock(ctx, attachment)
+		case attachmentCode:
+			block, err = s.codeBlock(ctx, attachment)
+		case attachmentText:
+			block, err = s.textBlock(ctx, attachment)
+		default:
+			reason := "unsupported file type"
+			if mediaType != "" {
+				reason = fmt.Sprintf("unsupported file type %s", mediaType)
+			}
+			blocks = append(blocks, attachmentNotice(attachment, reason))
+			continue
+		}
+
+		if err != nil {
+			s.logger.Warn("skipping attachment", "filename", attachment.Filename, "error", err)
+			blocks = append(blocks, attachmentNotice(attachment, err.Error()))
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// classifyAttachment detects an attachment's kind from its declared content type and extension
+func classifyAttachment(attachment *discordgo.MessageAttachment) (attachmentKind, string) {
+	if mediaType, ok := imageMediaType(attachment); ok {
+		return attachmentImage, mediaType
+	}
+
+	contentType, _, _ := strings.Cut(attachment.ContentType, ";")
+	ext := strings.ToLower(path.Ext(attachment.Filename))
+
+	switch {
+	case contentType == "application/pdf" || ext == ".pdf":
+		return attachmentPDF, "application/pdf"
+	case codeLanguages[ext] != "":
+		return attachmentCode, contentType
+	case textExtensions[ext] || strings.HasPrefix(contentType, "text/"):
+		return attachmentText, contentType
+	}
+
+	return attachmentUnsupported, contentType
+}
+
+// pdfBlock downloads a PDF attachment and encodes it as a base64 document block
+func (s *Service) pdfBlock(ctx context.Context, attachment *discordgo.MessageAttachment) (anthropic.ContentBlockParamUnion, error) {
+	data, err := s.downloadAttachment(ctx, attachment, s.maxPDFBytes)
+	if err != nil {
+		return anthropic.ContentBlockParamUnion{}, err
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("file is not a valid PDF")
+	}
+
+	block := anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{
+		Data: base64.StdEncoding.EncodeToString(data),
+	})
+	block.OfDocument.Title = anthropic.String(attachment.Filename)
+	return block, nil
+}
+
+// codeBlock downloads a source file and wraps it in a fenced code block labelled with its filename
+func (s *Service) codeBlock(ctx context.Context, attachment *discordgo.MessageAttachment) (anthropic.ContentBlockParamUnion, error) {
+	text, err := s.downloadText(ctx, attachment)
+	if err != nil {
+		return anthropic.ContentBlockParamUnion{}, err
+	}
+
+	// Use a fence longer than any backtick run in the file so it can't be closed early
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+
+	language := codeLanguages[strings.ToLower(path.Ext(attachment.Filename))]
+	return anthropic.NewTextBlock(fmt.Sprintf("File: %s\n%s%s\n%s\n%s", attachment.Filename, fence, language, text, fence)), nil
+}
+
+// textBlock downloads a plain text file and passes it as a text document titled with its filename
+func (s *Service) textBlock(ctx context.Context, attachment *discordgo.MessageAttachment) (anthropic.ContentBlockParamUnion, error) {
+	text, err := s.downloadText(ctx, attachment)
+	if err != nil {
+		return anthropic.ContentBlockParamUnion{}, err
+	}
+
+	block := anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: text})
+	block.OfDocument.Title = anthropic.String(attachment.Filename)
+	return block, nil
+}
+
+// downloadText downloads up to maxTextBytes of a text attachment, appending a marker if it was truncated
+func (s *Service) downloadText(ctx context.Context, attachment *discordgo.MessageAttachment) (string, error) {
+	data, truncated, err := s.downloadPrefix(ctx, attachment, s.maxTextBytes)
+	if err != nil {
+		return "", err
+	}
+
+	// Drop a rune split by the size cap before checking the encoding
+	if truncated {
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("file is not valid UTF-8 text")
+	}
+
+	text := string(data)
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("file is empty")
+	}
+	if truncated {
+		marker := fmt.Sprintf("only the first %s were included", formatBytes(len(data)))
+		if attachment.Size > len(data) {
+			marker = fmt.Sprintf("only the first %s of %s were included", formatBytes(len(data)), formatBytes(attachment.Size))
+		}
+		text += "\n[... truncated: " + marker + " ...]"
+	}
+	return text, nil
+}
+
+// imageBlock downloads an image attachment and encodes it as a base64 image block
+func (s *Service) imageBlock(ctx context.Context, attachment *discordgo.MessageAttachment, mediaType string) (anthropic.ContentBlockParamUnion, error) {
+	data, err := s.downloadAttachment(ctx, attachment, s.maxImageBytes)
+	if err != nil {
+		return anthropic.ContentBlockParamUnion{}, err
+	}
+
+	// Trust the bytes over the filename when they disagree
+	if sniffed := http.DetectContentType(data); strings.HasPrefix(sniffed, "image/") {
+		mediaType = sniffed
+	}
+	if !isImageMediaType(mediaType) {
+		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("unsupported image format %s", mediaType)
+	}
+
+	return anthropic.NewImageBlockBase64(mediaType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// downloadAttachment fetches an attachment, refusing anything larger than maxBytes
+func (s *Service) downloadAttachment(ctx context.Context, attachment *discordgo.MessageAttachment, maxBytes int) ([]byte, error) {
+	if attachment.Size > maxBytes {
+		return nil, fmt.Errorf("file is larger than %s", formatBytes(maxBytes))
+	}
+
+	data, truncated, err := s.downloadPrefix(ctx, attachment, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, fmt.Errorf("file is larger than %s", formatBytes(maxBytes))
+	}
+
+	return data, nil
+}
+
+// downloadPrefix fetches at most maxBytes of an attachment and reports whether it was cut short
+func (s *Service) downloadPrefix(ctx context.Context, attachment *discordgo.MessageAttachment, maxBytes int) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, attachment.URL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create download request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to download file: %s", resp.Status)
+	}
+
+	// Read one byte past the limit to detect files whose declared size was wrong
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to download file: %w", err)
+	}
+	if len(data) > maxBytes {
+		return data[:maxBytes], true, nil
+	}
+
+	return data, false, nil
+}
+
+// imageMediaType reports the image media type of an attachment, if it is a supported image
+func imageMediaType(attachment *discordgo.MessageAttachment) (string, bool) {
+	contentType, _, _ := strings.Cut(attachment.ContentType, ";")
+	if isImageMediaType(contentType) {
+		return contentType, true
+	}
+
+	mediaType, ok := imageMediaTypes[strings.ToLower(path.Ext(attachment.Filename))]
+	return mediaType, ok
+}
+
+// isImageMediaType reports whether Claude accepts images of the given media type
+func isImageMediaType(mediaType string) bool {
+	for _, supported := range imageMediaTypes {
+		if mediaType == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentNotice creates a text block telling Claude an attachment was not included
+func attachmentNotice(attachment *discordgo.MessageAttachment, reason string) anthropic.ContentBlockParamUnion {
+	return anthropic.NewTextBlock(fmt.Sprintf("[Attachment %q was not included: %s]", attachment.Filename, reason))
+}
+
+// formatBytes formats a byte count for display, e.g. "5.0 MB"
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
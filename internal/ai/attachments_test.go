@@ -0,0 +1,266 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/charmbracelet/log"
+)
+
+// pngData starts with the PNG signature, which is all content sniffing looks at
+const pngData = "\x89PNG\r\n\x1a\nimage data"
+
+// newAttachmentStub serves the given files by path and returns a service that downloads from it
+func newAttachmentStub(t *testing.T, files map[string]string) (*Service, string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, data)
+	}))
+	t.Cleanup(server.Close)
+
+	s := &Service{
+		logger:        log.New(io.Discard),
+		httpClient:    server.Client(),
+		maxImages:     2,
+		maxImageBytes: 64,
+		maxTextBytes:  64,
+		maxPDFBytes:   64,
+	}
+	return s, server.URL
+}
+
+func TestClassifyImageAttachment(t *testing.T) {
+	tests := []struct {
+		name          string
+		filename      string
+		contentType   string
+		wantKind      attachmentKind
+		wantMediaType string
+	}{
+		{name: "declared type", filename: "photo", contentType: "image/png", wantKind: attachmentImage, wantMediaType: "image/png"},
+		{name: "declared type with parameters", filename: "photo", contentType: "image/webp; q=1", wantKind: attachmentImage, wantMediaType: "image/webp"},
+		{name: "extension", filename: "photo.JPG", wantKind: attachmentImage, wantMediaType: "image/jpeg"},
+		{name: "unsupported image type", filename: "photo.bmp", contentType: "image/bmp", wantKind: attachmentUnsupported, wantMediaType: "image/bmp"},
+		{name: "unknown file", filename: "archive.zip", contentType: "application/zip", wantKind: attachmentUnsupported, wantMediaType: "application/zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, mediaType := classifyAttachment(&discordgo.MessageAttachment{Filename: tt.filename, ContentType: tt.contentType})
+			if kind != tt.wantKind || mediaType != tt.wantMediaType {
+				t.Errorf("classifyAttachment = %v, %q, want %v, %q", kind, mediaType, tt.wantKind, tt.wantMediaType)
+			}
+		})
+	}
+}
+
+func TestImageBlock(t *testing.T) {
+	s, url := newAttachmentStub(t, map[string]string{
+		"/photo.png":  pngData,
+		"/misnamed":   pngData,
+		"/bitmap.png": "BM" + strings.Repeat("\x00", 30),
+		"/huge.png":   pngData + strings.Repeat("x", 100),
+	})
+
+	tests := []struct {
+		name          string
+		path          string
+		mediaType     string
+		size          int
+		wantMediaType string
+		wantErr       string
+	}{
+		{name: "image", path: "/photo.png", mediaType: "image/png", wantMediaType: "image/png"},
+		{name: "bytes win over the name", path: "/misnamed", mediaType: "image/jpeg", wantMediaType: "image/png"},
+		{name: "unsupported format", path: "/bitmap.png", mediaType: "image/png", wantErr: "unsupported image format image/bmp"},
+		{name: "declared too large", path: "/photo.png", mediaType: "image/png", size: 65, wantErr: "larger than 64 bytes"},
+		{name: "larger than declared", path: "/huge.png", mediaType: "image/png", size: 10, wantErr: "larger than 64 bytes"},
+		{name: "download failed", path: "/missing.png", mediaType: "image/png", wantErr: "404 Not Found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachment := &discordgo.MessageAttachment{Filename: tt.path[1:], URL: url + tt.path, Size: tt.size}
+			block, err := s.imageBlock(context.Background(), attachment, tt.mediaType)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("imageBlock error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("imageBlock: %v", err)
+			}
+			if got := string(block.OfImage.Source.OfBase64.MediaType); got != tt.wantMediaType {
+				t.Errorf("media type = %q, want %q", got, tt.wantMediaType)
+			}
+		})
+	}
+}
+
+func TestAttachmentBlocksLimitsImages(t *testing.T) {
+	s, url := newAttachmentStub(t, map[string]string{"/a.png": pngData, "/b.png": pngData, "/c.png": pngData})
+	message := &discordgo.Message{}
+	for _, name := range []string{"missing.png", "a.png", "b.png", "c.png"} {
+		message.Attachments = append(message.Attachments, &discordgo.MessageAttachment{Filename: name, URL: url + "/" + name})
+	}
+
+	blocks := s.attachmentBlocks(context.Background(), message)
+	if len(blocks) != 4 {
+		t.Fatalf("got %d blocks, want 4", len(blocks))
+	}
+	// A failed download doesn't count towards the limit
+	if text := blocks[0].OfText; text == nil || !strings.Contains(text.Text, "404 Not Found") {
+		t.Errorf("block 0 = %+v, want a download failure notice", blocks[0])
+	}
+	for i := 1; i <= 2; i++ {
+		if blocks[i].OfImage == nil {
+			t.Errorf("block %d isn't an image", i)
+		}
+	}
+	if text := blocks[3].OfText; text == nil || !strings.Contains(text.Text, "only 2 images are read per message") {
+		t.Errorf("block 3 = %+v, want the image limit notice", blocks[3])
+	}
+}
+
+func TestClassifyDocumentAttachment(t *testing.T) {
+	tests := []struct {
+		name          string
+		filename      string
+		contentType   string
+		wantKind      attachmentKind
+		wantMediaType string
+	}{
+		{name: "PDF by type", filename: "report", contentType: "application/pdf", wantKind: attachmentPDF, wantMediaType: "application/pdf"},
+		{name: "PDF by extension", filename: "report.PDF", wantKind: attachmentPDF, wantMediaType: "application/pdf"},
+		{name: "code", filename: "main.go", contentType: "text/x-go; charset=utf-8", wantKind: attachmentCode, wantMediaType: "text/x-go"},
+		{name: "code with a generic type", filename: "query.sql", contentType: "application/octet-stream", wantKind: attachmentCode, wantMediaType: "application/octet-stream"},
+		{name: "text by extension", filename: "notes.md", wantKind: attachmentText},
+		{name: "text by type", filename: "README", contentType: "text/plain; charset=utf-8", wantKind: attachmentText, wantMediaType: "text/plain"},
+		{name: "binary", filename: "program.exe", contentType: "application/x-msdownload", wantKind: attachmentUnsupported, wantMediaType: "application/x-msdownload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, mediaType := classifyAttachment(&discordgo.MessageAttachment{Filename: tt.filename, ContentType: tt.contentType})
+			if kind != tt.wantKind || mediaType != tt.wantMediaType {
+				t.Errorf("classifyAttachment = %v, %q, want %v, %q", kind, mediaType, tt.wantKind, tt.wantMediaType)
+			}
+		})
+	}
+}
+
+func TestDownloadText(t *testing.T) {
+	s, url := newAttachmentStub(t, map[string]string{
+		"/short.txt":  "hello\n",
+		"/long.txt":   strings.Repeat("a", 100),
+		"/split.txt":  strings.Repeat("a", 63) + "é and more",
+		"/latin1.txt": "caf\xe9",
+		"/blank.txt":  " \n\t\n",
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		size    int
+		want    string
+		wantErr string
+	}{
+		{name: "whole file", path: "/short.txt", want: "hello\n"},
+		{
+			name: "truncated at the limit",
+			path: "/long.txt",
+			size: 100,
+			want: strings.Repeat("a", 64) + "\n[... truncated: only the first 64 bytes of 100 bytes were included ...]",
+		},
+		{
+			name: "truncated without a declared size",
+			path: "/long.txt",
+			want: strings.Repeat("a", 64) + "\n[... truncated: only the first 64 bytes were included ...]",
+		},
+		{
+			name: "character split by the limit dropped",
+			path: "/split.txt",
+			want: strings.Repeat("a", 63) + "\n[... truncated: only the first 63 bytes were included ...]",
+		},
+		{name: "not UTF-8", path: "/latin1.txt", wantErr: "not valid UTF-8"},
+		{name: "blank", path: "/blank.txt", wantErr: "file is empty"},
+		{name: "download failed", path: "/missing.txt", wantErr: "404 Not Found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachment := &discordgo.MessageAttachment{Filename: tt.path[1:], URL: url + tt.path, Size: tt.size}
+			text, err := s.downloadText(context.Background(), attachment)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("downloadText error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadText: %v", err)
+			}
+			if text != tt.want {
+				t.Errorf("downloadText = %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFBlock(t *testing.T) {
+	s, url := newAttachmentStub(t, map[string]string{
+		"/report.pdf": "%PDF-1.7 document",
+		"/fake.pdf":   "<html>not a pdf</html>",
+		"/large.pdf":  "%PDF-1.7" + strings.Repeat("x", 100),
+	})
+
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{path: "/report.pdf"},
+		{path: "/fake.pdf", wantErr: "not a valid PDF"},
+		{path: "/large.pdf", wantErr: "larger than 64 bytes"},
+	}
+
+	for _, tt := range tests {
+		attachment := &discordgo.MessageAttachment{Filename: tt.path[1:], URL: url + tt.path}
+		block, err := s.pdfBlock(context.Background(), attachment)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("pdfBlock(%s) error = %v, want %q", tt.path, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("pdfBlock(%s): %v", tt.path, err)
+		}
+		if title := block.OfDocument.Title.Value; title != "report.pdf" {
+			t.Errorf("pdfBlock(%s) title = %q", tt.path, title)
+		}
+	}
+}
+
+func TestCodeBlockFence(t *testing.T) {
+	s, url := newAttachmentStub(t, map[string]string{"/README.go": "// ```go\n// x := 1\n// ````"})
+
+	block, err := s.codeBlock(context.Background(), &discordgo.MessageAttachment{Filename: "README.go", URL: url + "/README.go"})
+	if err != nil {
+		t.Fatalf("codeBlock: %v", err)
+	}
+	want := "File: README.go\n`````go\n// ```go\n// x := 1\n// ````\n`````"
+	if got := block.OfText.Text; got != want {
+		t.Errorf("codeBlock = %q, want %q", got, want)
+	}
+}
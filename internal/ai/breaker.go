@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	// BreakerClosed lets requests through normally
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen refuses requests until its cooldown passes
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single trial request through after the cooldown; its success
+	// closes the breaker and its failure opens it again
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus is a snapshot of a circuit breaker
+type BreakerStatus struct {
+	State    BreakerState
+	Failures int       // consecutive failures
+	RetryAt  time.Time // when an open breaker lets a trial request through
+	LastErr  error
+}
+
+// String describes the status for people, e.g. in the menu bar
+func (s BreakerStatus) String() string {
+	switch s.State {
+	case BreakerOpen:
+		return fmt.Sprintf("AI provider: unavailable, retrying at %s", s.RetryAt.Local().Format("15:04:05"))
+	case BreakerHalfOpen:
+		return "AI provider: recovering"
+	default:
+		return "AI provider: available"
+	}
+}
+
+// CircuitOpenError is returned instead of calling the API while the breaker is open
+type CircuitOpenError struct {
+	RetryAt time.Time
+}
+
+// Error implements the error interface
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open until %s", e.RetryAt.Format(time.RFC3339))
+}
+
+// CircuitBreaker stops calling the API after repeated transient failures, so an outage
+// isn't made worse by every message retrying against it
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	lastErr   error
+	probing   bool // a half-open trial request is in flight
+	listeners []func(BreakerStatus)
+
+	// pending holds state changes waiting to be delivered to the listeners, in order, by a
+	// single goroutine that runs while delivering is set
+	pending    []BreakerStatus
+	delivering bool
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive failures and
+// allows a trial request once cooldown has passed. A threshold of 0 never opens.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// OnChange registers fn to be called whenever the breaker changes state. Listeners run on
+// their own goroutine, one change at a time, so a slow listener never holds up a request.
+func (b *CircuitBreaker) OnChange(fn func(BreakerStatus)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// Status returns the breaker's current status
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status()
+}
+
+// Check returns a *CircuitOpenError if a request would be refused right now, without
+// claiming the half-open trial the way Allow does
+func (b *CircuitBreaker) Check() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.refusal()
+}
+
+// Allow returns a *CircuitOpenError if the breaker is open, or nil if a request may be made.
+// Once the cooldown has passed, only one caller at a time is allowed through as the trial
+// request; it must report back with Success, Failure or Release.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.refusal(); err != nil {
+		return err
+	}
+	switch b.state {
+	case BreakerOpen:
+		b.probing = true
+		b.transition(BreakerHalfOpen)
+	case BreakerHalfOpen:
+		b.probing = true
+	}
+	return nil
+}
+
+// Success records a successful request, closing the breaker
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	if b.state == BreakerClosed {
+		return
+	}
+	b.lastErr = nil
+	b.transition(BreakerClosed)
+}
+
+// Failure records a transient failure, opening the breaker once there are too many in a
+// row or when a trial request fails
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.lastErr = err
+	b.probing = false
+	if b.threshold <= 0 || (b.state == BreakerClosed && b.failures < b.threshold) || b.state == BreakerOpen {
+		return
+	}
+	b.openedAt = time.Now()
+	b.transition(BreakerOpen)
+}
+
+// Release gives up a request allowed through without saying whether the API is healthy,
+// e.g. when it was cancelled, so another trial request may be made
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// refusal returns the error for a refused request, or nil; the lock must be held
+func (b *CircuitBreaker) refusal() error {
+	switch b.state {
+	case BreakerOpen:
+		if retryAt := b.openedAt.Add(b.cooldown); time.Now().Before(retryAt) {
+			return &CircuitOpenError{RetryAt: retryAt}
+		}
+	case BreakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{RetryAt: time.Now().Add(b.cooldown)}
+		}
+	}
+	return nil
+}
+
+// transition changes state and queues the listeners to be notified; the lock must be held
+func (b *CircuitBreaker) transition(state BreakerState) {
+	b.state = state
+	if len(b.listeners) == 0 {
+		return
+	}
+	b.pending = append(b.pending, b.status())
+	if !b.delivering {
+		b.delivering = true
+		go b.deliver()
+	}
+}
+
+// deliver notifies the listeners of queued state changes until there are none left
+func (b *CircuitBreaker) deliver() {
+	for {
+		b.mu.Lock()
+		if len(b.pending) == 0 {
+			b.delivering = false
+			b.mu.Unlock()
+			return
+		}
+		status := b.pending[0]
+		b.pending = b.pending[1:]
+		listeners := b.listeners
+		b.mu.Unlock()
+
+		for _, fn := range listeners {
+			fn(status)
+		}
+	}
+}
+
+// status returns the current status; the lock must be held
+func (b *CircuitBreaker) status() BreakerStatus {
+	status := BreakerStatus{State: b.state, Failures: b.failures, LastErr: b.lastErr}
+	if b.state == BreakerOpen {
+		status.RetryAt = b.openedAt.Add(b.cooldown)
+	}
+	return status
+}
+
+// unavailableExplanation tells the user the API is down and when the bot will try it again,
+// using a Discord relative timestamp
+func unavailableExplanation(retryAt time.Time) string {
+	return fmt.Sprintf("Sorry, I can't reach my AI provider right now. 🔌 I'll try again <t:%d:R>!", retryAt.Unix())
+}
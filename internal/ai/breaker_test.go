@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerHalfOpenAllowsOneTrial(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Millisecond)
+	b.Failure(errors.New("overloaded"))
+	b.Failure(errors.New("overloaded"))
+	if err := b.Allow(); err == nil {
+		t.Fatal("open breaker allowed a request during its cooldown")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("first request after cooldown refused: %v", err)
+	}
+	if got := b.Status().State; got != BreakerHalfOpen {
+		t.Fatalf("state = %s, want %s", got, BreakerHalfOpen)
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("second request allowed while the trial was in flight")
+	}
+	if err := b.Check(); err == nil {
+		t.Fatal("Check passed while the trial was in flight")
+	}
+
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("request after a released trial refused: %v", err)
+	}
+	b.Success()
+	if got := b.Status().State; got != BreakerClosed {
+		t.Fatalf("state = %s, want %s", got, BreakerClosed)
+	}
+	for range 3 {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("closed breaker refused a request: %v", err)
+		}
+	}
+}
+
+func TestCircuitBreakerNotifiesInOrder(t *testing.T) {
+	b := NewCircuitBreaker(1, 0)
+	changes := make(chan BreakerState, 3)
+	b.OnChange(func(status BreakerStatus) {
+		changes <- status.State
+	})
+
+	b.Failure(errors.New("overloaded"))
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow after cooldown: %v", err)
+	}
+	b.Success()
+
+	for _, want := range []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerClosed} {
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Fatalf("change = %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no change to %s delivered", want)
+		}
+	}
+}
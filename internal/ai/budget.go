@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Budget limits how much work a single request may do. Zero values mean no limit.
+// MaxToolErrors counts failures in a row across the conversation rather than the request.
+type Budget struct {
+	MaxToolRounds   int
+	MaxToolErrors   int
+	MaxInputTokens  int64
+	MaxOutputTokens int64
+	Timeout         time.Duration
+}
+
+// Names of the budgets, used in logs when one runs out
+const (
+	budgetToolRounds   = "tool_rounds"
+	budgetToolErrors   = "tool_errors"
+	budgetInputTokens  = "input_tokens"
+	budgetOutputTokens = "output_tokens"
+	budgetDeadline     = "deadline"
+)
+
+// budgetExhaustedNotice is sent as the result of tool calls skipped because a tool budget ran out
+const budgetExhaustedNotice = "Not run: the tool budget for this request is used up, or too many tool calls failed in a row. " +
+	"Answer now using only the information you already have, and say what you couldn't check."
+
+// budgetTracker tracks the resources used by a single request against its budget
+type budgetTracker struct {
+	budget       Budget
+	rounds       int
+	toolErrors   int // consecutive failed tool calls in the conversation, including earlier requests
+	inputTokens  int64
+	outputTokens int64
+}
+
+// newBudgetTracker creates a tracker for a new request
+func newBudgetTracker(budget Budget) *budgetTracker {
+	return &budgetTracker{budget: budget}
+}
+
+// record adds the token usage of one API response
+func (t *budgetTracker) record(usage anthropic.Usage) {
+	t.inputTokens += usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	t.outputTokens += usage.OutputTokens
+}
+
+// exhausted returns the name of the first budget that has run out, or an empty string
+func (t *budgetTracker) exhausted() string {
+	switch {
+	case t.budget.MaxInputTokens > 0 && t.inputTokens >= t.budget.MaxInputTokens:
+		return budgetInputTokens
+	case t.budget.MaxOutputTokens > 0 && t.outputTokens >= t.budget.MaxOutputTokens:
+		return budgetOutputTokens
+	case t.budget.MaxToolRounds > 0 && t.rounds >= t.budget.MaxToolRounds:
+		return budgetToolRounds
+	case t.budget.MaxToolErrors > 0 && t.toolErrors >= t.budget.MaxToolErrors:
+		return budgetToolErrors
+	}
+	return ""
+}
+
+// budgetAllowsFinalAnswer reports whether Claude can still be asked for a final answer
+// without tools after the given budget runs out
+func budgetAllowsFinalAnswer(budget string) bool {
+	return budget == budgetToolRounds || budget == budgetToolErrors
+}
+
+// budgetExplanation returns the message shown to users when a budget stops a request without an answer
+func budgetExplanation(budget string) string {
+	switch budget {
+	case budgetDeadline:
+		return "Sorry, that took too long and I had to stop before finishing. ⏱️ Try asking for something smaller?"
+	case budgetToolRounds:
+		return "Sorry, I used up all my tool calls for this request without reaching an answer. 🛠️ Try breaking it into smaller questions?"
+	case budgetToolErrors:
+		return "Sorry, my tools kept failing so I couldn't finish that. 🛠️ Please try again in a bit!"
+	default:
+		return "Sorry, that request got too big for me to finish in one go. 📚 Try breaking it into smaller questions?"
+	}
+}
@@ -0,0 +1,34 @@
+package ai
+
+import "testing"
+
+func TestToolErrorsKeptWithConversation(t *testing.T) {
+	s := &Service{budget: Budget{MaxToolErrors: 3}}
+
+	steps := []struct {
+		channelID string
+		succeeded bool // a tool call succeeded before the failures
+		failures  int
+		want      int
+	}{
+		{channelID: "a", failures: 2, want: 2},
+		{channelID: "b", failures: 1, want: 1},
+		{channelID: "a", failures: 0, want: 2}, // a request without tool calls keeps the count
+		{channelID: "a", failures: 1, want: 0}, // the cap was hit, so the count starts over
+		{channelID: "b", succeeded: true, want: 0},
+	}
+
+	for i, step := range steps {
+		budget := newBudgetTracker(s.budget)
+		budget.toolErrors = s.consecutiveToolErrors(step.channelID)
+		if step.succeeded {
+			budget.toolErrors = 0
+		}
+		budget.toolErrors += step.failures
+		s.saveToolErrors(step.channelID, budget)
+
+		if got := s.consecutiveToolErrors(step.channelID); got != step.want {
+			t.Errorf("step %d: %s has %d tool errors, want %d", i, step.channelID, got, step.want)
+		}
+	}
+}
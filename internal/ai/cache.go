@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"maps"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
+)
+
+// addCacheBreakpoints marks the system prompt, the tool list and the conversation so far
+// for prompt caching. Each round of the tool loop, and the next message in the channel,
+// then reads the unchanged prefix from the cache instead of paying for it again.
+// Breakpoints are set on copies, since params share blocks with the registry and history.
+func addCacheBreakpoints(params *anthropic.MessageNewParams) {
+	if n := len(params.System); n > 0 {
+		system := make([]anthropic.TextBlockParam, n)
+		copy(system, params.System)
+		system[n-1].CacheControl = anthropic.NewCacheControlEphemeralParam()
+		params.System = system
+	}
+
+	if n := len(params.Tools); n > 0 {
+		tools := make([]anthropic.ToolUnionParam, n)
+		copy(tools, params.Tools)
+		tools[n-1] = toolWithCacheControl(tools[n-1])
+		params.Tools = tools
+	}
+
+	// The rolling breakpoint goes on the last block that can carry one
+	if n := len(params.Messages); n > 0 {
+		last := params.Messages[n-1]
+		for i := len(last.Content) - 1; i >= 0; i-- {
+			block, ok := blockWithCacheControl(last.Content[i])
+			if !ok {
+				continue
+			}
+			content := make([]anthropic.ContentBlockParamUnion, len(last.Content))
+			copy(content, last.Content)
+			content[i] = block
+			last.Content = content
+
+			messages := make([]anthropic.MessageParam, n)
+			copy(messages, params.Messages)
+			messages[n-1] = last
+			params.Messages = messages
+			break
+		}
+	}
+}
+
+// toolWithCacheControl returns a copy of a tool with a cache breakpoint
+func toolWithCacheControl(tool anthropic.ToolUnionParam) anthropic.ToolUnionParam {
+	switch {
+	case tool.OfTool != nil:
+		t := *tool.OfTool
+		t.CacheControl = anthropic.NewCacheControlEphemeralParam()
+		return anthropic.ToolUnionParam{OfTool: &t}
+	case tool.OfWebSearchTool20250305 != nil:
+		t := *tool.OfWebSearchTool20250305
+		t.CacheControl = anthropic.NewCacheControlEphemeralParam()
+		return anthropic.ToolUnionParam{OfWebSearchTool20250305: &t}
+	}
+	// Tools the SDK doesn't model, such as code execution, are raw JSON objects
+	if fields, ok := tool.Overrides(); ok {
+		if fields, ok := fields.(map[string]any); ok {
+			t := maps.Clone(fields)
+			t["cache_control"] = anthropic.NewCacheControlEphemeralParam()
+			return param.Override[anthropic.ToolUnionParam](t)
+		}
+	}
+	return tool
+}
+
+// blockWithCacheControl returns a copy of a content block with a cache breakpoint.
+// Thinking blocks can't carry one, so ok is false for them.
+func blockWithCacheControl(block anthropic.ContentBlockParamUnion) (anthropic.ContentBlockParamUnion, bool) {
+	cacheControl := anthropic.NewCacheControlEphemeralParam()
+	switch {
+	case block.OfText != nil:
+		b := *block.OfText
+		b.CacheControl = cacheControl
+		return anthropic.ContentBlockParamUnion{OfText: &b}, true
+	case block.OfImage != nil:
+		b := *block.OfImage
+		b.CacheControl = cacheControl
+		return anthropic.ContentBlockParamUnion{OfImage: &b}, true
+	case block.OfDocument != nil:
+		b := *block.OfDocument
+		b.CacheControl = cacheControl
+		return anthropic.ContentBlockParamUnion{OfDocument: &b}, true
+	case block.OfToolUse != nil:
+		b := *block.OfToolUse
+		b.CacheControl = cacheControl
+		return anthropic.ContentBlockParamUnion{OfToolUse: &b}, true
+	case block.OfToolResult != nil:
+		b := *block.OfToolResult
+		b.CacheControl = cacheControl
+		return anthropic.ContentBlockParamUnion{OfToolResult: &b}, true
+	case block.OfServerToolUse != nil:
+		b := *block.OfServerToolUse
+		b.CacheControl = cacheControl
+		return anthropic.ContentBlockParamUnion{OfServerToolUse: &b}, true
+	case block.OfWebSearchToolResult != nil:
+		b := *block.OfWebSearchToolResult
+		b.CacheControl = cacheControl
+		return anthropic.ContentBlockParamUnion{OfWebSearchToolResult: &b}, true
+	}
+	return block, false
+}
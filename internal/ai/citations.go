@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// footnotes numbers the web sources cited across a reply, so each cited passage can be
+// followed by markers like [1] and the sources listed at the end
+type footnotes struct {
+	urls    []string
+	titles  []string
+	numbers map[string]int // URL -> footnote number
+}
+
+// markers returns the footnote markers for a text block's citations, numbering new sources
+func (f *footnotes) markers(citations []anthropic.TextCitationUnion) string {
+	var b strings.Builder
+	seen := make(map[int]bool)
+	for _, citation := range citations {
+		if citation.URL == "" {
+			continue
+		}
+		number, ok := f.numbers[citation.URL]
+		if !ok {
+			if f.numbers == nil {
+				f.numbers = make(map[string]int)
+			}
+			f.urls = append(f.urls, citation.URL)
+			f.titles = append(f.titles, citation.Title)
+			number = len(f.urls)
+			f.numbers[citation.URL] = number
+		}
+		if !seen[number] {
+			seen[number] = true
+			fmt.Fprintf(&b, "[%d]", number)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return " " + b.String()
+}
+
+// String renders the cited sources as small Discord footnote links, one per line.
+// Links are wrapped in <> so Discord doesn't embed a preview of each.
+func (f *footnotes) String() string {
+	lines := make([]string, len(f.urls))
+	for i, url := range f.urls {
+		title := strings.NewReplacer("[", "(", "]", ")").Replace(strings.TrimSpace(f.titles[i]))
+		if title == "" {
+			title = url
+		}
+		lines[i] = fmt.Sprintf("-# [%d] [%s](<%s>)", i+1, title, url)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// responseText joins a response's text blocks the way Claude wrote them, marking cited
+// passages with footnote markers. Text on either side of a server tool call becomes
+// separate paragraphs.
+func responseText(resp *anthropic.Message, notes *footnotes) string {
+	var b strings.Builder
+	separate := false
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			if separate && b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			separate = false
+			b.WriteString(block.Text)
+			b.WriteString(notes.markers(block.Citations))
+		case "server_tool_use", "tool_use":
+			separate = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
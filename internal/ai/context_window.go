@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/bwmarrin/discordgo"
+
+	"discord-assist/internal/conversation"
+)
+
+const (
+	// imageTokens approximates what an attached image costs
+	imageTokens = 1600
+	// pdfBytesPerToken roughly converts a PDF's file size to tokens; most of a PDF is layout,
+	// fonts and images rather than text
+	pdfBytesPerToken = 64
+	// summaryMaxTokens caps the length of a conversation summary
+	summaryMaxTokens = 1024
+	// summaryMaxInputTokens caps the transcript sent to the summary model; the oldest
+	// messages are left out of longer ones
+	summaryMaxInputTokens = 100000
+	// summaryToolResultLength is how much of each tool result is shown to the summarizer
+	summaryToolResultLength = 300
+	// summaryHeader introduces the running summary at the start of the context
+	summaryHeader = "[Summary of the earlier conversation]\n"
+)
+
+// contextWindow returns the stored conversation to send with a request, leaving room for
+// reserved tokens of new turns. When the history no longer fits, its older turns are
+// compacted into the running summary, which is saved with the conversation.
+func (s *Service) contextWindow(ctx context.Context, req *request, conv *conversation.Conversation, reserved int) []anthropic.MessageParam {
+	messages := conv.Messages
+	summary := conv.Summary
+
+	budget := s.contextTokens - reserved
+	if len(messages) > 0 && estimateTokens(messages) > budget {
+		// Keep the newest turns verbatim in half the budget, leaving room to grow before
+		// the next compaction
+		cut := compactionPoint(messages, budget/2)
+		compacted, err := s.summarize(ctx, req, summary, messages[:cut])
+		if err != nil {
+			s.logger.Warn("failed to summarize conversation, dropping older messages from context", "channelID", req.channelID, "error", err)
+		} else if err := s.store.Compact(req.channelID, compacted, cut); err != nil {
+			s.logger.Error("failed to save conversation summary", "channelID", req.channelID, "error", err)
+			summary = compacted
+		} else {
+			s.logger.Info("compacted conversation", "channelID", req.channelID, "messages", cut)
+			summary = compacted
+		}
+		messages = messages[cut:]
+	}
+
+	var window []anthropic.MessageParam
+	if summary != "" {
+		window = append(window, anthropic.NewUserMessage(anthropic.NewTextBlock(summaryHeader+summary)))
+	}
+	return append(window, messages...)
+}
+
+// compactionPoint returns how many of the oldest messages to compact so the rest fit in
+// keepTokens. The kept messages must start at a user turn that isn't a tool result, so
+// no tool call is separated from its result. At least one message is always compacted.
+func compactionPoint(messages []anthropic.MessageParam, keepTokens int) int {
+	cut := len(messages)
+	kept := 0
+	for i := len(messages) - 1; i > 0; i-- {
+		kept += estimateTokens(messages[i : i+1])
+		if kept > keepTokens {
+			break
+		}
+		if isTurnStart(messages[i]) {
+			cut = i
+		}
+	}
+	return cut
+}
+
+// isTurnStart reports whether a message can begin the context: a user message carrying
+// no tool results
+func isTurnStart(message anthropic.MessageParam) bool {
+	if message.Role != anthropic.MessageParamRoleUser {
+		return false
+	}
+	for _, block := range message.Content {
+		if block.OfToolResult != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// summarize folds messages into the previous summary using the summary model
+func (s *Service) summarize(ctx context.Context, req *request, previous string, messages []anthropic.MessageParam) (string, error) {
+	var rendered strings.Builder
+	for _, message := range messages {
+		writeTranscript(&rendered, message)
+	}
+	text := rendered.String()
+	if limit := summaryMaxInputTokens * charsPerToken; len(text) > limit {
+		start := len(text) - limit
+		for start < len(text) && !utf8.RuneStart(text[start]) {
+			start++
+		}
+		text = "[earlier messages omitted]\n\n" + text[start:]
+	}
+
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "Summary so far:\n%s\n\n", previous)
+	}
+	transcript.WriteString("Messages to add to the summary:\n\n")
+	transcript.WriteString(text)
+
+	resp, err := s.sendWithRetry(ctx, anthropic.MessageNewParams{
+		Model:     anthropic.Model(s.summaryModel),
+		MaxTokens: summaryMaxTokens,
+		System:    []anthropic.TextBlockParam{{Text: SummaryPrompt}},
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock(transcript.String()))},
+	}, nil, false)
+	if err != nil {
+		return "", err
+	}
+	s.recordUsage(req, string(resp.Model), resp.Usage)
+
+	var summary strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			summary.WriteString(block.Text)
+		}
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return "", fmt.Errorf("summary model returned no text")
+	}
+	return strings.TrimSpace(summary.String()), nil
+}
+
+// writeTranscript renders a message as plain text for the summarizer
+func writeTranscript(b *strings.Builder, message anthropic.MessageParam) {
+	speaker := "Assistant"
+	if message.Role == anthropic.MessageParamRoleUser {
+		speaker = "User"
+	}
+
+	for _, block := range message.Content {
+		switch {
+		case block.OfText != nil:
+			fmt.Fprintf(b, "%s: %s\n\n", speaker, block.OfText.Text)
+		case block.OfImage != nil:
+			fmt.Fprintf(b, "%s: [image]\n\n", speaker)
+		case block.OfDocument != nil:
+			fmt.Fprintf(b, "%s: [document: %s]\n\n", speaker, block.OfDocument.Title.Value)
+		case block.OfToolUse != nil:
+			input, _ := json.Marshal(block.OfToolUse.Input)
+			fmt.Fprintf(b, "%s: [called %s with %s]\n\n", speaker, block.OfToolUse.Name, input)
+		case block.OfToolResult != nil:
+			var result strings.Builder
+			for _, content := range block.OfToolResult.Content {
+				if content.OfText != nil {
+					result.WriteString(content.OfText.Text)
+				}
+			}
+			text, _ := truncateToTokens(result.String(), summaryToolResultLength/charsPerToken)
+			fmt.Fprintf(b, "[tool result: %s]\n\n", text)
+		case block.OfServerToolUse != nil:
+			input, _ := json.Marshal(block.OfServerToolUse.Input)
+			fmt.Fprintf(b, "%s: [called %s with %s]\n\n", speaker, block.OfServerToolUse.Name, input)
+		}
+	}
+}
+
+// estimateTokens approximates the tokens messages take up in a request
+func estimateTokens(messages []anthropic.MessageParam) int {
+	total := 0
+	for _, message := range messages {
+		for _, block := range message.Content {
+			total += estimateBlockTokens(block)
+		}
+	}
+	return total
+}
+
+// estimateBlockTokens approximates the tokens a content block takes up
+func estimateBlockTokens(block anthropic.ContentBlockParamUnion) int {
+	switch {
+	case block.OfText != nil:
+		return len(block.OfText.Text)/charsPerToken + 1
+	case block.OfImage != nil:
+		return imageTokens
+	case block.OfDocument != nil && block.OfDocument.Source.OfBase64 != nil:
+		return len(block.OfDocument.Source.OfBase64.Data) * 3 / 4 / pdfBytesPerToken
+	}
+	data, _ := json.Marshal(block)
+	return len(data)/charsPerToken + 1
+}
+
+// estimateDiscordTokens approximates the tokens a Discord message will take up once built
+// into a turn, including its speaker label and attachments
+func (s *Service) estimateDiscordTokens(msg *discordgo.Message) int {
+	tokens := (len(msg.Content)+len(speakerLabel(msg)))/charsPerToken + 1
+	for _, attachment := range msg.Attachments {
+		switch kind, _ := classifyAttachment(attachment); kind {
+		case attachmentImage:
+			tokens += imageTokens
+		case attachmentPDF:
+			tokens += min(attachment.Size, s.maxPDFBytes) / pdfBytesPerToken
+		case attachmentCode, attachmentText:
+			tokens += min(attachment.Size, s.maxTextBytes) / charsPerToken
+		}
+	}
+	return tokens
+}
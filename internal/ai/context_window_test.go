@@ -0,0 +1,45 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+func TestCompactionPoint(t *testing.T) {
+	text := func(s string) anthropic.ContentBlockParamUnion {
+		// 36 characters, estimated at 10 tokens
+		return anthropic.NewTextBlock(s + strings.Repeat(".", 36-len(s)))
+	}
+	messages := []anthropic.MessageParam{
+		anthropic.NewUserMessage(text("u0")),
+		anthropic.NewAssistantMessage(text("a1")),
+		anthropic.NewUserMessage(text("u2")),
+		anthropic.NewAssistantMessage(anthropic.NewToolUseBlock("toolu_1", map[string]any{"location": "Paris"}, "get_weather")),
+		anthropic.NewUserMessage(anthropic.NewToolResultBlock("toolu_1", "Sunny", false)),
+		anthropic.NewAssistantMessage(text("a5")),
+		anthropic.NewUserMessage(text("u6")),
+		anthropic.NewAssistantMessage(text("a7")),
+	}
+
+	tests := []struct {
+		name       string
+		keepTokens int
+		want       int
+	}{
+		{name: "nothing fits", keepTokens: 0, want: len(messages)},
+		{name: "last turn fits", keepTokens: 20, want: 6},
+		{name: "tool call and result aren't separated", keepTokens: estimateTokens(messages[3:]), want: 6},
+		{name: "cut at a turn before the tool call", keepTokens: estimateTokens(messages[2:]), want: 2},
+		{name: "first message always compacted", keepTokens: 1 << 20, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compactionPoint(messages, tt.keepTokens); got != tt.want {
+				t.Errorf("compactionPoint(keep %d) = %d, want %d", tt.keepTokens, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// continuePrompt asks Claude to carry on with a reply that was cut off, when the partial
+// reply can't be prefilled
+const continuePrompt = "Your reply was cut off by the length limit. Continue exactly where it stopped, without repeating anything or starting over."
+
+// truncatedNotice follows a reply that was still cut off once it ran out of continuations
+const truncatedNotice = "-# ✂️ This reply hit the length limit and was cut short."
+
+// continuation returns the turns that make Claude carry on with a reply cut off by the max
+// tokens limit. The partial reply is prefilled as the start of Claude's answer, so the next
+// response picks up mid-sentence. Prefilling isn't possible with extended thinking or when
+// no text came back, so then Claude is asked to continue instead.
+func continuation(resp *anthropic.Message, thinking bool) []anthropic.MessageParam {
+	blocks := partialReply(resp)
+	if n := len(blocks); !thinking && n > 0 && blocks[n-1].OfText != nil {
+		// The API rejects a prefill that ends in whitespace
+		text := *blocks[n-1].OfText
+		text.Text = strings.TrimRightFunc(text.Text, unicode.IsSpace)
+		blocks[n-1] = anthropic.ContentBlockParamUnion{OfText: &text}
+		return []anthropic.MessageParam{anthropic.NewAssistantMessage(blocks...)}
+	}
+
+	var turns []anthropic.MessageParam
+	if len(blocks) > 0 {
+		turns = append(turns, anthropic.NewAssistantMessage(blocks...))
+	}
+	return append(turns, anthropic.NewUserMessage(anthropic.NewTextBlock(continuePrompt)))
+}
+
+// partialReply returns the blocks of a reply cut off by the max tokens limit that can be
+// sent back. A tool call or thinking block that was cut off is incomplete, and the API
+// rejects empty text.
+func partialReply(resp *anthropic.Message) []anthropic.ContentBlockParamUnion {
+	blocks := assistantMessage(resp).Content
+	for n := len(blocks); n > 0; n = len(blocks) {
+		last := blocks[n-1]
+		cutOff := last.OfToolUse != nil || last.OfServerToolUse != nil || (last.OfThinking != nil && last.OfThinking.Signature == "")
+		if !cutOff && (last.OfText == nil || strings.TrimSpace(last.OfText.Text) != "") {
+			break
+		}
+		blocks = blocks[:n-1]
+	}
+	return blocks
+}
+
+// minStitchOverlap is the shortest repeat of a reply's end that stitch removes from its
+// continuation, so that short coincidences such as a repeated word are kept
+const minStitchOverlap = 20
+
+// stitch joins the text of a continued response onto the text before it, keeping any
+// whitespace the continuation starts with. Asked to continue rather than prefilled, Claude
+// sometimes repeats the end of the text so far, which is dropped.
+func stitch(partial string, resp *anthropic.Message, text string) string {
+	if partial == "" {
+		return text
+	}
+	if n := overlap(partial, text); n > 0 {
+		return partial + text[n:]
+	}
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			lead := block.Text[:len(block.Text)-len(strings.TrimLeftFunc(block.Text, unicode.IsSpace))]
+			return partial + lead + text
+		}
+	}
+	return partial + text
+}
+
+// overlap returns the length of the longest end of partial that text starts with, or 0 if
+// it is shorter than minStitchOverlap
+func overlap(partial, text string) int {
+	for n := min(len(partial), len(text)); n >= minStitchOverlap; n-- {
+		if strings.HasSuffix(partial, text[:n]) {
+			return n
+		}
+	}
+	return 0
+}
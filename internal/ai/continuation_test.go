@@ -0,0 +1,175 @@
+package ai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// messageFrom decodes a response with the given content blocks
+func messageFrom(t *testing.T, content string) *anthropic.Message {
+	t.Helper()
+	var message anthropic.Message
+	if err := json.Unmarshal([]byte(`{"role":"assistant","content":`+content+`}`), &message); err != nil {
+		t.Fatalf("bad test message: %v", err)
+	}
+	return &message
+}
+
+func TestStitch(t *testing.T) {
+	tests := []struct {
+		name    string
+		partial string
+		content string
+		text    string
+		want    string
+	}{
+		{
+			name:    "first response",
+			content: `[{"type":"text","text":"Hello"}]`,
+			text:    "Hello",
+			want:    "Hello",
+		},
+		{
+			name:    "continues mid-word",
+			partial: "The quick bro",
+			content: `[{"type":"text","text":"wn fox"}]`,
+			text:    "wn fox",
+			want:    "The quick brown fox",
+		},
+		{
+			name:    "space at the join kept",
+			partial: "The quick",
+			content: `[{"type":"text","text":" brown fox"}]`,
+			text:    "brown fox",
+			want:    "The quick brown fox",
+		},
+		{
+			name:    "paragraph break at the join kept",
+			partial: "First paragraph.",
+			content: `[{"type":"text","text":"\n\nSecond paragraph."}]`,
+			text:    "Second paragraph.",
+			want:    "First paragraph.\n\nSecond paragraph.",
+		},
+		{
+			name:    "whitespace from a later block ignored",
+			partial: "Searching",
+			content: `[{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{}},{"type":"text","text":" done"},{"type":"text","text":"\n\nmore"}]`,
+			text:    "done\n\nmore",
+			want:    "Searching done\n\nmore",
+		},
+		{
+			name:    "no text blocks",
+			partial: "Let me check",
+			content: `[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}]`,
+			want:    "Let me check",
+		},
+		{
+			name:    "repeated end dropped",
+			partial: "Step one is to preheat the oven to 200 degrees",
+			content: `[{"type":"text","text":"preheat the oven to 200 degrees, then mix the flour."}]`,
+			text:    "preheat the oven to 200 degrees, then mix the flour.",
+			want:    "Step one is to preheat the oven to 200 degrees, then mix the flour.",
+		},
+		{
+			name:    "whole reply repeated",
+			partial: "Here is the first part of the list:",
+			content: `[{"type":"text","text":"Here is the first part of the list:\n- one"}]`,
+			text:    "Here is the first part of the list:\n- one",
+			want:    "Here is the first part of the list:\n- one",
+		},
+		{
+			name:    "short repeat kept",
+			partial: "It was very",
+			content: `[{"type":"text","text":" very good"}]`,
+			text:    "very good",
+			want:    "It was very very good",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stitch(tt.partial, messageFrom(t, tt.content), tt.text); got != tt.want {
+				t.Errorf("stitch = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartialReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "text kept",
+			content: `[{"type":"text","text":"Half a sent"}]`,
+			want:    `[{"text":"Half a sent","type":"text"}]`,
+		},
+		{
+			name:    "cut off tool call dropped",
+			content: `[{"type":"text","text":"Checking."},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"loc":"Par"}}]`,
+			want:    `[{"text":"Checking.","type":"text"}]`,
+		},
+		{
+			name:    "cut off server tool call dropped",
+			content: `[{"type":"text","text":"Searching."},{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{}}]`,
+			want:    `[{"text":"Searching.","type":"text"}]`,
+		},
+		{
+			name:    "unsigned thinking dropped",
+			content: `[{"type":"thinking","thinking":"Let me","signature":""}]`,
+			want:    `[]`,
+		},
+		{
+			name:    "signed thinking kept",
+			content: `[{"type":"thinking","thinking":"Done.","signature":"sig"},{"type":"text","text":" "}]`,
+			want:    `[{"signature":"sig","thinking":"Done.","type":"thinking"}]`,
+		},
+		{
+			name:    "whitespace text dropped",
+			content: `[{"type":"text","text":"Answer"},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}},{"type":"text","text":"\n\n"}]`,
+			want:    `[{"text":"Answer","type":"text"}]`,
+		},
+		{
+			name:    "nothing left",
+			content: `[]`,
+			want:    `[]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := partialReply(messageFrom(t, tt.content))
+			if blocks == nil {
+				blocks = []anthropic.ContentBlockParamUnion{}
+			}
+			got, err := json.Marshal(blocks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("partialReply = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContinuationPrefill(t *testing.T) {
+	resp := messageFrom(t, `[{"type":"text","text":"The list:\n- one\n"}]`)
+
+	turns := continuation(resp, false)
+	if len(turns) != 1 || turns[0].Role != anthropic.MessageParamRoleAssistant {
+		t.Fatalf("continuation = %d turns, want one prefilled assistant turn", len(turns))
+	}
+	if got := turns[0].Content[0].OfText.Text; got != "The list:\n- one" {
+		t.Errorf("prefill = %q, want its trailing whitespace trimmed", got)
+	}
+
+	turns = continuation(resp, true)
+	if len(turns) != 2 || turns[1].Content[0].OfText.Text != continuePrompt {
+		t.Errorf("continuation with thinking = %d turns, want the reply and a prompt to continue", len(turns))
+	}
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/bwmarrin/discordgo"
+
+	"discord-assist/internal/conversation"
+)
+
+const (
+	// historyPageSize is how many Discord messages are fetched per page of channel history
+	historyPageSize = 50
+	// maxHistoryPages bounds how far back a single request pages through channel history
+	maxHistoryPages = 10
+)
+
+// lockChannel acquires the per-channel lock and returns a function that releases it
+func (s *Service) lockChannel(channelID string) func() {
+	value, _ := s.channelLocks.LoadOrStore(channelID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// unseenMessages returns the Discord messages, newest first, that still need to be added to
+// the conversation. It pages back through channel history until it reaches the last stored
+// message or the context token budget is used up, so new conversations are seeded with as
+// much recent history as fits. Established conversations skip this bot's own replies since
+// those are already stored as assistant turns.
+func (s *Service) unseenMessages(conv *conversation.Conversation, message *discordgo.Message) []*discordgo.Message {
+	page, err := s.messenger.GetRecentMessages(message.ChannelID, historyPageSize)
+	if err != nil || len(page) == 0 {
+		s.logger.Error("failed to fetch recent messages", "channelID", message.ChannelID, "error", err)
+		// Fallback to just the current message
+		page = []*discordgo.Message{message}
+	}
+
+	botUserID := s.messenger.UserID()
+	established := len(conv.Messages) > 0 || conv.Summary != ""
+
+	var unseen []*discordgo.Message
+	tokens := 0
+	for pages := 1; ; pages++ {
+		for _, msg := range page {
+			if !isNewerMessage(msg.ID, conv.LastMessageID) {
+				return unseen
+			}
+			if established && msg.Author.ID == botUserID {
+				continue
+			}
+			tokens += s.estimateDiscordTokens(msg)
+			if tokens > s.contextTokens && len(unseen) > 0 {
+				return unseen
+			}
+			unseen = append(unseen, msg)
+		}
+
+		if len(page) < historyPageSize || pages >= maxHistoryPages {
+			return unseen
+		}
+		page, err = s.messenger.GetMessagesBefore(message.ChannelID, page[len(page)-1].ID, historyPageSize)
+		if err != nil {
+			s.logger.Warn("failed to fetch message history", "channelID", message.ChannelID, "error", err)
+			return unseen
+		}
+	}
+}
+
+// saveConversation appends new turns to the store and records the newest Discord message seen
+func (s *Service) saveConversation(channelID string, messages []anthropic.MessageParam, lastMessageID string) {
+	if err := s.store.Append(channelID, messages...); err != nil {
+		s.logger.Error("failed to save conversation", "channelID", channelID, "error", err)
+		return
+	}
+	if err := s.store.MarkSeen(channelID, lastMessageID); err != nil {
+		s.logger.Error("failed to save conversation", "channelID", channelID, "error", err)
+	}
+}
+
+// isNewerMessage reports whether Discord snowflake id was created after last.
+// Snowflakes are decimal strings, so a longer ID is always the newer one.
+func isNewerMessage(id, last string) bool {
+	if len(id) != len(last) {
+		return len(id) > len(last)
+	}
+	return id > last
+}
@@ -0,0 +1,493 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// openAIMaxErrorBytes caps how much of an error response body is kept
+const openAIMaxErrorBytes = 64 * 1024
+
+// OpenAIProvider sends requests to an OpenAI-compatible chat completions API, such as a
+// local Ollama or llama.cpp server. Requests and responses are translated from and to the
+// Anthropic types; server tools, citations and prompt caching have no equivalent and are
+// left out.
+type OpenAIProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAIProvider creates a provider for the API at baseURL (e.g. http://localhost:11434/v1).
+// model is used for every request in place of the Claude model requested; when empty, the
+// requested model is passed through unchanged.
+func NewOpenAIProvider(baseURL, apiKey, model string) *OpenAIProvider {
+	return &OpenAIProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{},
+	}
+}
+
+// Name implements Provider
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+// openAIRequest is a chat completions request
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	Tools         []openAITool         `json:"tools,omitempty"`
+	ToolChoice    string               `json:"tool_choice,omitempty"`
+	MaxTokens     int64                `json:"max_tokens,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// openAIStreamOptions asks for token usage at the end of a stream
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIMessage is a chat message; Content is a string, a list of parts, or nil for an
+// assistant message that only calls tools
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    any              `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIContentPart is a text or image part of a user message
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+// openAIImageURL is an image, by URL or as a data: URL
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// openAIToolCall is a function call made by the assistant. Index is only set in streamed deltas.
+type openAIToolCall struct {
+	Index    *int               `json:"index,omitempty"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+// openAIFunctionCall is the function and JSON-encoded arguments of a tool call
+type openAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// openAITool is a function the model may call
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+// openAIFunction declares a function and the JSON Schema of its parameters
+type openAIFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// openAIResponse is a chat completion, or one chunk of a streamed one
+type openAIResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openAIResponseMessage `json:"message"`
+		Delta        openAIResponseMessage `json:"delta"`
+		FinishReason string                `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+	Error json.RawMessage `json:"error"`
+}
+
+// openAIResponseMessage is the assistant message, or the delta to it, in a response
+type openAIResponseMessage struct {
+	Content   string           `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls"`
+}
+
+// CreateMessage implements Provider
+func (p *OpenAIProvider) CreateMessage(ctx context.Context, params anthropic.MessageNewParams) (*anthropic.Message, error) {
+	body, err := p.post(ctx, p.request(params, false))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var resp openAIResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("chat completion has no choices")
+	}
+
+	result := openAIResult{id: resp.ID, model: resp.Model}
+	choice := resp.Choices[0]
+	result.text.WriteString(choice.Message.Content)
+	result.toolCalls = choice.Message.ToolCalls
+	result.finishReason = choice.FinishReason
+	if resp.Usage != nil {
+		result.inputTokens = resp.Usage.PromptTokens
+		result.outputTokens = resp.Usage.CompletionTokens
+	}
+	return result.message()
+}
+
+// StreamMessage implements Provider
+func (p *OpenAIProvider) StreamMessage(ctx context.Context, params anthropic.MessageNewParams, handler StreamHandler) (*anthropic.Message, error) {
+	body, err := p.post(ctx, p.request(params, true))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var result openAIResult
+	textOpen := false
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if len(chunk.Error) > 0 {
+			return nil, fmt.Errorf("received error while streaming: %s", chunk.Error)
+		}
+		result.id = cmp.Or(result.id, chunk.ID)
+		result.model = cmp.Or(result.model, chunk.Model)
+		if chunk.Usage != nil {
+			result.inputTokens = chunk.Usage.PromptTokens
+			result.outputTokens = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		choice := chunk.Choices[0]
+		if choice.Delta.Content != "" {
+			result.text.WriteString(choice.Delta.Content)
+			handler.Text(choice.Delta.Content)
+			textOpen = true
+		}
+		for _, delta := range choice.Delta.ToolCalls {
+			if textOpen {
+				handler.TextDone(nil)
+				textOpen = false
+			}
+			result.addToolCallDelta(delta)
+		}
+		if choice.FinishReason != "" {
+			result.finishReason = choice.FinishReason
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if textOpen {
+		handler.TextDone(nil)
+	}
+
+	return result.message()
+}
+
+// post sends a chat completions request, returning the response body on success
+func (p *OpenAIProvider) post(ctx context.Context, request openAIRequest) (io.ReadCloser, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode chat completion request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, openAIMaxErrorBytes))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Header: resp.Header, Body: strings.TrimSpace(string(body))}
+	}
+	return resp.Body, nil
+}
+
+// request translates Anthropic message params into a chat completions request
+func (p *OpenAIProvider) request(params anthropic.MessageNewParams, stream bool) openAIRequest {
+	request := openAIRequest{
+		Model:     cmp.Or(p.model, string(params.Model)),
+		MaxTokens: params.MaxTokens,
+		Stream:    stream,
+	}
+	if stream {
+		request.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	if params.Temperature.Valid() {
+		temperature := params.Temperature.Value
+		request.Temperature = &temperature
+	}
+
+	var system []string
+	for _, block := range params.System {
+		system = append(system, block.Text)
+	}
+	if len(system) > 0 {
+		request.Messages = append(request.Messages, openAIMessage{Role: "system", Content: strings.Join(system, "\n\n")})
+	}
+	for _, message := range params.Messages {
+		if message.Role == anthropic.MessageParamRoleAssistant {
+			request.Messages = append(request.Messages, openAIAssistantMessage(message))
+		} else {
+			request.Messages = append(request.Messages, openAIUserMessages(message)...)
+		}
+	}
+
+	for _, tool := range params.Tools {
+		if tool.OfTool == nil {
+			continue // server tools only exist on Anthropic's side
+		}
+		schema, err := json.Marshal(tool.OfTool.InputSchema)
+		if err != nil {
+			continue
+		}
+		request.Tools = append(request.Tools, openAITool{
+			Type: "function",
+			Function: openAIFunction{
+				Name:        tool.OfTool.Name,
+				Description: tool.OfTool.Description.Value,
+				Parameters:  schema,
+			},
+		})
+	}
+	if len(request.Tools) > 0 && params.ToolChoice.OfNone != nil {
+		request.ToolChoice = "none"
+	}
+
+	return request
+}
+
+// openAIAssistantMessage translates an assistant turn, turning tool_use blocks into tool calls
+func openAIAssistantMessage(message anthropic.MessageParam) openAIMessage {
+	var text []string
+	var toolCalls []openAIToolCall
+	for _, block := range message.Content {
+		switch {
+		case block.OfText != nil:
+			text = append(text, block.OfText.Text)
+		case block.OfToolUse != nil:
+			arguments, err := json.Marshal(block.OfToolUse.Input)
+			if err != nil {
+				arguments = []byte("{}")
+			}
+			toolCalls = append(toolCalls, openAIToolCall{
+				ID:       block.OfToolUse.ID,
+				Type:     "function",
+				Function: openAIFunctionCall{Name: block.OfToolUse.Name, Arguments: string(arguments)},
+			})
+		}
+	}
+
+	out := openAIMessage{Role: "assistant", ToolCalls: toolCalls}
+	if len(text) > 0 {
+		out.Content = strings.Join(text, "\n\n")
+	}
+	return out
+}
+
+// openAIUserMessages translates a user turn. Tool results become separate tool messages,
+// which must directly follow the assistant message that called them.
+func openAIUserMessages(message anthropic.MessageParam) []openAIMessage {
+	var messages []openAIMessage
+	var parts []openAIContentPart
+	textOnly := true
+	for _, block := range message.Content {
+		switch {
+		case block.OfToolResult != nil:
+			messages = append(messages, openAIMessage{
+				Role:       "tool",
+				ToolCallID: block.OfToolResult.ToolUseID,
+				Content:    openAIToolResultText(block.OfToolResult),
+			})
+		case block.OfText != nil:
+			parts = append(parts, openAIContentPart{Type: "text", Text: block.OfText.Text})
+		case block.OfImage != nil:
+			source := block.OfImage.Source
+			var url string
+			switch {
+			case source.OfBase64 != nil:
+				url = fmt.Sprintf("data:%s;base64,%s", source.OfBase64.MediaType, source.OfBase64.Data)
+			case source.OfURL != nil:
+				url = source.OfURL.URL
+			default:
+				continue
+			}
+			parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+			textOnly = false
+		case block.OfDocument != nil:
+			document := block.OfDocument
+			if document.Source.OfText != nil {
+				parts = append(parts, openAIContentPart{Type: "text", Text: fmt.Sprintf("Document: %s\n%s", document.Title.Value, document.Source.OfText.Data)})
+			} else {
+				parts = append(parts, openAIContentPart{Type: "text", Text: fmt.Sprintf("[Document %q was not included: this model can't read PDFs]", document.Title.Value)})
+			}
+		}
+	}
+
+	if len(parts) == 0 {
+		return messages
+	}
+	if textOnly {
+		text := make([]string, len(parts))
+		for i, part := range parts {
+			text[i] = part.Text
+		}
+		return append(messages, openAIMessage{Role: "user", Content: strings.Join(text, "\n\n")})
+	}
+	return append(messages, openAIMessage{Role: "user", Content: parts})
+}
+
+// openAIToolResultText flattens a tool result to text, marking errors
+func openAIToolResultText(result *anthropic.ToolResultBlockParam) string {
+	var b strings.Builder
+	if result.IsError.Value {
+		b.WriteString("Error: ")
+	}
+	for _, content := range result.Content {
+		switch {
+		case content.OfText != nil:
+			b.WriteString(content.OfText.Text)
+		case content.OfImage != nil:
+			b.WriteString("[image]")
+		}
+	}
+	return b.String()
+}
+
+// openAIResult collects a chat completion, complete or streamed, to convert it into an
+// Anthropic message
+type openAIResult struct {
+	id           string
+	model        string
+	text         strings.Builder
+	toolCalls    []openAIToolCall
+	finishReason string
+	inputTokens  int64
+	outputTokens int64
+}
+
+// addToolCallDelta merges a streamed piece of a tool call into the call at its index
+func (r *openAIResult) addToolCallDelta(delta openAIToolCall) {
+	index := len(r.toolCalls)
+	if delta.Index != nil {
+		index = *delta.Index
+	}
+	for len(r.toolCalls) <= index {
+		r.toolCalls = append(r.toolCalls, openAIToolCall{})
+	}
+	call := &r.toolCalls[index]
+	call.ID = cmp.Or(call.ID, delta.ID)
+	call.Function.Name += delta.Function.Name
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+// openAIStopReasons maps finish reasons to Anthropic stop reasons
+var openAIStopReasons = map[string]string{
+	"stop":       "end_turn",
+	"length":     "max_tokens",
+	"tool_calls": "tool_use",
+}
+
+// message converts the result into an Anthropic message. It goes through JSON so the
+// content block unions are filled in the same way as for an Anthropic response.
+func (r *openAIResult) message() (*anthropic.Message, error) {
+	content := []map[string]any{}
+	if text := r.text.String(); text != "" {
+		content = append(content, map[string]any{"type": "text", "text": text})
+	}
+	for i, call := range r.toolCalls {
+		if call.Function.Name == "" {
+			continue
+		}
+		id := call.ID
+		if id == "" {
+			// Some local servers leave out call IDs; tool results are matched up by them
+			id = fmt.Sprintf("call_%d", i)
+		}
+		arguments := json.RawMessage(call.Function.Arguments)
+		if strings.TrimSpace(call.Function.Arguments) == "" {
+			// Calls to tools without parameters may leave out the arguments entirely
+			arguments = json.RawMessage("{}")
+		} else if !json.Valid(arguments) {
+			// Pass the text on as a JSON string, which tool validation reports back to the
+			// model as bad input instead of running the tool with made-up arguments
+			arguments, _ = json.Marshal(call.Function.Arguments)
+		}
+		content = append(content, map[string]any{"type": "tool_use", "id": id, "name": call.Function.Name, "input": arguments})
+	}
+
+	stopReason := cmp.Or(openAIStopReasons[r.finishReason], "end_turn")
+	if len(r.toolCalls) > 0 {
+		// Not every server reports tool_calls as the finish reason
+		stopReason = "tool_use"
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"id":          r.id,
+		"type":        "message",
+		"role":        "assistant",
+		"model":       r.model,
+		"content":     content,
+		"stop_reason": stopReason,
+		"usage":       map[string]any{"input_tokens": r.inputTokens, "output_tokens": r.outputTokens},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var message anthropic.Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, fmt.Errorf("failed to convert chat completion: %w", err)
+	}
+	return &message, nil
+}
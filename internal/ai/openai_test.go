@@ -0,0 +1,295 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// recordingHandler is a StreamHandler that records what it's told
+type recordingHandler struct {
+	text      strings.Builder
+	textDones int
+}
+
+func (h *recordingHandler) Text(delta string)                        { h.text.WriteString(delta) }
+func (h *recordingHandler) TextDone(_ []anthropic.TextCitationUnion) { h.textDones++ }
+func (h *recordingHandler) ServerToolStart()                         {}
+func (h *recordingHandler) ThinkingDone(_ string)                    {}
+
+// newOpenAIStub serves each chat completions request with respond, recording the request
+func newOpenAIStub(t *testing.T, respond func(w http.ResponseWriter, request openAIRequest)) (*httptest.Server, *openAIRequest, *http.Header) {
+	t.Helper()
+	var got openAIRequest
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		header = r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		got = openAIRequest{}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		respond(w, got)
+	}))
+	t.Cleanup(server.Close)
+	return server, &got, &header
+}
+
+func TestOpenAIProviderRequest(t *testing.T) {
+	server, got, header := newOpenAIStub(t, func(w http.ResponseWriter, _ openAIRequest) {
+		fmt.Fprint(w, `{"id":"c1","model":"llama3.1","choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`)
+	})
+	provider := NewOpenAIProvider(server.URL+"/v1/", "secret", "llama3.1")
+
+	params := anthropic.MessageNewParams{
+		Model:       "claude-sonnet-4-5",
+		MaxTokens:   500,
+		Temperature: anthropic.Float(0.5),
+		System:      []anthropic.TextBlockParam{{Text: "Be brief."}, {Text: "Be kind."}},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock("What's the weather?"), anthropic.NewImageBlockBase64("image/png", "AAAA")),
+			anthropic.NewAssistantMessage(
+				anthropic.NewTextBlock("Checking."),
+				anthropic.NewToolUseBlock("toolu_1", map[string]any{"location": "Paris"}, "get_weather"),
+			),
+			anthropic.NewUserMessage(
+				anthropic.NewToolResultBlock("toolu_1", "Sunny", false),
+				anthropic.NewTextBlock("Thanks"),
+			),
+		},
+		Tools: []anthropic.ToolUnionParam{
+			{OfTool: &anthropic.ToolParam{
+				Name:        "get_weather",
+				Description: anthropic.String("Gets the weather"),
+				InputSchema: anthropic.ToolInputSchemaParam{Properties: map[string]any{"location": map[string]any{"type": "string"}}},
+			}},
+			{OfWebSearchTool20250305: &anthropic.WebSearchTool20250305Param{}},
+		},
+	}
+
+	if _, err := provider.CreateMessage(context.Background(), params); err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+
+	if auth := header.Get("Authorization"); auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if got.Model != "llama3.1" || got.MaxTokens != 500 || got.Temperature == nil || *got.Temperature != 0.5 || got.Stream {
+		t.Errorf("request settings = model %q, max tokens %d, temperature %v, stream %v", got.Model, got.MaxTokens, got.Temperature, got.Stream)
+	}
+
+	wantRoles := []string{"system", "user", "assistant", "tool", "user"}
+	if len(got.Messages) != len(wantRoles) {
+		t.Fatalf("got %d messages, want %d: %+v", len(got.Messages), len(wantRoles), got.Messages)
+	}
+	for i, role := range wantRoles {
+		if got.Messages[i].Role != role {
+			t.Errorf("message %d role = %q, want %q", i, got.Messages[i].Role, role)
+		}
+	}
+	if got.Messages[0].Content != "Be brief.\n\nBe kind." {
+		t.Errorf("system content = %q", got.Messages[0].Content)
+	}
+	if parts, ok := got.Messages[1].Content.([]any); !ok || len(parts) != 2 {
+		t.Errorf("user content with an image = %#v, want two parts", got.Messages[1].Content)
+	} else if part := parts[1].(map[string]any); part["type"] != "image_url" ||
+		part["image_url"].(map[string]any)["url"] != "data:image/png;base64,AAAA" {
+		t.Errorf("image part = %#v", part)
+	}
+
+	assistant := got.Messages[2]
+	if assistant.Content != "Checking." || len(assistant.ToolCalls) != 1 {
+		t.Fatalf("assistant message = %+v", assistant)
+	}
+	call := assistant.ToolCalls[0]
+	if call.ID != "toolu_1" || call.Type != "function" || call.Function.Name != "get_weather" || call.Function.Arguments != `{"location":"Paris"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+	if tool := got.Messages[3]; tool.ToolCallID != "toolu_1" || tool.Content != "Sunny" {
+		t.Errorf("tool message = %+v", tool)
+	}
+	if got.Messages[4].Content != "Thanks" {
+		t.Errorf("user text after the tool result = %#v", got.Messages[4].Content)
+	}
+
+	if len(got.Tools) != 1 {
+		t.Fatalf("got %d tools, want only the custom one", len(got.Tools))
+	}
+	function := got.Tools[0].Function
+	if function.Name != "get_weather" || function.Description != "Gets the weather" || !strings.Contains(string(function.Parameters), `"location"`) {
+		t.Errorf("tool = %+v, parameters %s", function, function.Parameters)
+	}
+}
+
+func TestOpenAIProviderCreateMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		wantText    string
+		wantStop    anthropic.StopReason
+		wantTools   []string // name and input of each tool_use block
+		wantIDs     []string
+		wantInput   int64
+		wantOutput  int64
+		wantErrCode int
+	}{
+		{
+			name:       "text",
+			response:   `{"id":"c1","model":"m","choices":[{"message":{"content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`,
+			wantText:   "Hello",
+			wantStop:   anthropic.StopReasonEndTurn,
+			wantInput:  12,
+			wantOutput: 3,
+		},
+		{
+			name:     "truncated",
+			response: `{"choices":[{"message":{"content":"Hel"},"finish_reason":"length"}]}`,
+			wantText: "Hel",
+			wantStop: anthropic.StopReasonMaxTokens,
+		},
+		{
+			name: "tool calls without IDs or a tool_calls finish reason",
+			response: `{"choices":[{"message":{"content":"","tool_calls":[
+				{"type":"function","function":{"name":"get_weather","arguments":"{\"location\":\"Paris\"}"}},
+				{"id":"x2","type":"function","function":{"name":"get_time","arguments":""}}
+			]},"finish_reason":"stop"}]}`,
+			wantStop:  anthropic.StopReasonToolUse,
+			wantTools: []string{`get_weather {"location":"Paris"}`, `get_time {}`},
+			wantIDs:   []string{"call_0", "x2"},
+		},
+		{
+			name:      "invalid arguments passed on as a string",
+			response:  `{"choices":[{"message":{"tool_calls":[{"id":"x1","function":{"name":"get_weather","arguments":"{location: Paris"}}]},"finish_reason":"tool_calls"}]}`,
+			wantStop:  anthropic.StopReasonToolUse,
+			wantTools: []string{`get_weather "{location: Paris"`},
+			wantIDs:   []string{"x1"},
+		},
+		{
+			name:        "error status",
+			response:    "",
+			wantErrCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _, _ := newOpenAIStub(t, func(w http.ResponseWriter, _ openAIRequest) {
+				if tt.wantErrCode != 0 {
+					http.Error(w, `{"error":"busy"}`, tt.wantErrCode)
+					return
+				}
+				fmt.Fprint(w, tt.response)
+			})
+			provider := NewOpenAIProvider(server.URL+"/v1", "", "m")
+
+			message, err := provider.CreateMessage(context.Background(), anthropic.MessageNewParams{
+				Messages: []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("hi"))},
+			})
+			if tt.wantErrCode != 0 {
+				var statusErr *StatusError
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantErrCode {
+					t.Fatalf("error = %v, want status %d", err, tt.wantErrCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateMessage: %v", err)
+			}
+			checkOpenAIMessage(t, message, tt.wantText, tt.wantStop, tt.wantTools, tt.wantIDs)
+			if message.Usage.InputTokens != tt.wantInput || message.Usage.OutputTokens != tt.wantOutput {
+				t.Errorf("usage = %d in, %d out, want %d, %d", message.Usage.InputTokens, message.Usage.OutputTokens, tt.wantInput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestOpenAIProviderStreamMessage(t *testing.T) {
+	chunks := []string{
+		`{"id":"c1","model":"m","choices":[{"delta":{"content":"Let me "}}]}`,
+		`{"choices":[{"delta":{"content":"check."}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"loca"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"tion\":\"Paris\"}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":40,"completion_tokens":9}}`,
+	}
+	server, got, _ := newOpenAIStub(t, func(w http.ResponseWriter, _ openAIRequest) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, ": keep-alive\n\ndata: [DONE]\n\n")
+	})
+	provider := NewOpenAIProvider(server.URL+"/v1", "", "m")
+
+	handler := &recordingHandler{}
+	message, err := provider.StreamMessage(context.Background(), anthropic.MessageNewParams{
+		Messages: []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("hi"))},
+	}, handler)
+	if err != nil {
+		t.Fatalf("StreamMessage: %v", err)
+	}
+
+	if !got.Stream || got.StreamOptions == nil || !got.StreamOptions.IncludeUsage {
+		t.Errorf("request didn't ask for a stream with usage: %+v", got)
+	}
+	if handler.text.String() != "Let me check." || handler.textDones != 1 {
+		t.Errorf("handler saw %q with %d TextDone calls", handler.text.String(), handler.textDones)
+	}
+	checkOpenAIMessage(t, message, "Let me check.", anthropic.StopReasonToolUse,
+		[]string{`get_weather {"location":"Paris"}`, `get_time {}`}, []string{"call_a", "call_b"})
+	if message.ID != "c1" || message.Usage.InputTokens != 40 || message.Usage.OutputTokens != 9 {
+		t.Errorf("message ID %q, usage %d in, %d out", message.ID, message.Usage.InputTokens, message.Usage.OutputTokens)
+	}
+}
+
+func TestOpenAIProviderStreamError(t *testing.T) {
+	server, _, _ := newOpenAIStub(t, func(w http.ResponseWriter, _ openAIRequest) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"error\":{\"message\":\"model crashed\"}}\n\n")
+	})
+	provider := NewOpenAIProvider(server.URL+"/v1", "", "m")
+
+	_, err := provider.StreamMessage(context.Background(), anthropic.MessageNewParams{}, &recordingHandler{})
+	if err == nil || !strings.Contains(err.Error(), "model crashed") {
+		t.Fatalf("error = %v, want the streamed error", err)
+	}
+}
+
+// checkOpenAIMessage compares a translated message's text, stop reason and tool calls
+func checkOpenAIMessage(t *testing.T, message *anthropic.Message, wantText string, wantStop anthropic.StopReason, wantTools, wantIDs []string) {
+	t.Helper()
+	var text string
+	var tools, ids []string
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			text += block.Text
+		case "tool_use":
+			tools = append(tools, block.Name+" "+string(block.Input))
+			ids = append(ids, block.ID)
+		}
+	}
+	if text != wantText {
+		t.Errorf("text = %q, want %q", text, wantText)
+	}
+	if message.StopReason != wantStop {
+		t.Errorf("stop reason = %q, want %q", message.StopReason, wantStop)
+	}
+	if fmt.Sprint(tools) != fmt.Sprint(wantTools) || fmt.Sprint(ids) != fmt.Sprint(wantIDs) {
+		t.Errorf("tool calls = %v %v, want %v %v", tools, ids, wantTools, wantIDs)
+	}
+}
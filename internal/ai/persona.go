@@ -0,0 +1,263 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// defaultPersonaName is the persona used where none is assigned
+	defaultPersonaName = "default"
+	// personaExt is the extension of persona template files
+	personaExt = ".tmpl"
+	// personaAssignmentsFile names the file in the persona directory that assigns personas
+	// to guilds and channels
+	personaAssignmentsFile = "personas.json"
+)
+
+// PersonaData is what persona templates can use, e.g. {{.ChannelName}}
+type PersonaData struct {
+	Persona      string // name of the persona being rendered
+	BotName      string
+	GuildName    string // empty in direct messages
+	ChannelName  string
+	ChannelTopic string
+	UserName     string // display name of the person who sent the message
+	UserMention  string // <@id> mention of the person who sent the message
+	// Date is today's date in UTC, e.g. "Monday, January 2, 2006". There's no time of day,
+	// so the rendered prompt stays the same long enough to be cached.
+	Date string
+}
+
+// personaAssignments picks a persona by name for each guild and channel. Thinking sets the
+// extended thinking budget of personas by name.
+type personaAssignments struct {
+	Default  string            `json:"default"`
+	Guilds   map[string]string `json:"guilds"`
+	Channels map[string]string `json:"channels"`
+	Thinking map[string]int    `json:"thinking"`
+}
+
+// Personas holds the persona templates from a directory: one <name>.tmpl file per persona,
+// plus an optional personas.json assigning them to guilds and channels. The directory is
+// checked for changes at most once per interval, and reloaded when any file changes.
+type Personas struct {
+	dir      string
+	interval time.Duration
+	logger   *log.Logger
+
+	mu          sync.Mutex
+	checked     time.Time
+	signature   string
+	templates   map[string]*template.Template
+	assignments personaAssignments
+}
+
+// LoadPersonas loads the personas in dir. An empty dir uses DefaultPersona everywhere.
+func LoadPersonas(dir string, interval time.Duration, logger *log.Logger) (*Personas, error) {
+	p := &Personas{dir: dir, interval: interval, logger: logger}
+	if dir == "" {
+		templates, err := parsePersona(defaultPersonaName, DefaultPersona, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.templates = templates
+		return p, nil
+	}
+
+	signature, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	p.signature = signature
+	p.checked = time.Now()
+	return p, nil
+}
+
+// Render renders the system prompt for a message from the given guild and channel.
+// A persona that is missing or fails to render falls back to the default one.
+func (p *Personas) Render(guildID, channelID string, data PersonaData) string {
+	p.mu.Lock()
+	p.refresh()
+	name := p.assigned(guildID, channelID)
+	templates := p.templates
+	p.mu.Unlock()
+
+	tmpl, ok := templates[name]
+	if !ok {
+		p.logger.Warn("persona not found, using the default", "persona", name)
+		name, tmpl = defaultPersonaName, templates[defaultPersonaName]
+	}
+
+	data.Persona = name
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		p.logger.Error("failed to render persona, using the default", "persona", name, "error", err)
+		b.Reset()
+		data.Persona = defaultPersonaName
+		if err := templates[defaultPersonaName].Execute(&b, data); err != nil {
+			return ConversationPrompt
+		}
+	}
+	return strings.TrimSpace(b.String()) + "\n\n" + ConversationPrompt
+}
+
+// ThinkingBudget returns the extended thinking budget set for the persona of a guild and
+// channel, if any
+func (p *Personas) ThinkingBudget(guildID, channelID string) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	budget, ok := p.assignments.Thinking[p.assigned(guildID, channelID)]
+	return budget, ok
+}
+
+// assigned returns the name of the persona for a guild and channel; the lock must be held
+func (p *Personas) assigned(guildID, channelID string) string {
+	if name, ok := p.assignments.Channels[channelID]; ok {
+		return name
+	}
+	if name, ok := p.assignments.Guilds[guildID]; ok && guildID != "" {
+		return name
+	}
+	if p.assignments.Default != "" {
+		return p.assignments.Default
+	}
+	return defaultPersonaName
+}
+
+// refresh reloads the personas if the directory has changed since it was last checked.
+// Errors are logged and the previous personas kept. The lock must be held.
+func (p *Personas) refresh() {
+	if p.dir == "" || time.Since(p.checked) < p.interval {
+		return
+	}
+	p.checked = time.Now()
+
+	signature, err := p.scan()
+	if err != nil {
+		p.logger.Error("failed to check persona directory", "dir", p.dir, "error", err)
+		return
+	}
+	if signature == p.signature {
+		return
+	}
+	if err := p.load(); err != nil {
+		p.logger.Error("failed to reload personas, keeping the previous ones", "dir", p.dir, "error", err)
+		return
+	}
+	p.signature = signature
+	p.logger.Info("reloaded personas", "dir", p.dir, "personas", len(p.templates))
+}
+
+// scan returns a signature of the directory's persona files, which changes whenever a file
+// is added, removed or modified
+func (p *Personas) scan() (string, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read persona directory: %w", err)
+	}
+
+	var b strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || (filepath.Ext(entry.Name()) != personaExt && entry.Name() != personaAssignmentsFile) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// load parses every persona file and the assignments, replacing the current ones only if
+// all of them are valid
+func (p *Personas) load() error {
+	templates, err := parsePersona(defaultPersonaName, DefaultPersona, nil)
+	if err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(p.dir, "*"+personaExt))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read persona: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), personaExt)
+		if templates, err = parsePersona(name, string(text), templates); err != nil {
+			return err
+		}
+	}
+
+	var assignments personaAssignments
+	data, err := os.ReadFile(filepath.Join(p.dir, personaAssignmentsFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read persona assignments: %w", err)
+	default:
+		if err := json.Unmarshal(data, &assignments); err != nil {
+			return fmt.Errorf("failed to parse persona assignments: %w", err)
+		}
+	}
+
+	p.templates = templates
+	p.assignments = assignments
+	return nil
+}
+
+// parsePersona parses a persona template and adds it to templates, which is created if nil
+func parsePersona(name, text string, templates map[string]*template.Template) (map[string]*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse persona %s: %w", name, err)
+	}
+	if templates == nil {
+		templates = make(map[string]*template.Template)
+	}
+	templates[name] = tmpl
+	return templates, nil
+}
+
+// systemPrompt renders the persona for the channel a message was sent in
+func (s *Service) systemPrompt(message *discordgo.Message) string {
+	data := PersonaData{
+		BotName:     s.messenger.UserName(),
+		UserName:    displayName(message),
+		UserMention: message.Author.Mention(),
+		Date:        time.Now().UTC().Format("Monday, January 2, 2006"),
+	}
+	if channel, err := s.messenger.Channel(message.ChannelID); err != nil {
+		s.logger.Warn("failed to look up channel for persona", "channelID", message.ChannelID, "error", err)
+	} else {
+		data.ChannelName = channel.Name
+		data.ChannelTopic = channel.Topic
+	}
+	if message.GuildID != "" {
+		if guild, err := s.messenger.Guild(message.GuildID); err != nil {
+			s.logger.Warn("failed to look up guild for persona", "guildID", message.GuildID, "error", err)
+		} else {
+			data.GuildName = guild.Name
+		}
+	}
+	return s.personas.Render(message.GuildID, message.ChannelID, data)
+}
@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// Provider sends requests to an LLM backend. Requests and responses use the Anthropic
+// Messages API types the rest of the service is built on, including the stored history;
+// other backends translate to and from their own formats.
+type Provider interface {
+	// Name identifies the backend in logs
+	Name() string
+	// CreateMessage sends a request and returns the complete response
+	CreateMessage(ctx context.Context, params anthropic.MessageNewParams) (*anthropic.Message, error)
+	// StreamMessage sends a request, reporting the response to handler as it arrives, and
+	// returns the complete response
+	StreamMessage(ctx context.Context, params anthropic.MessageNewParams, handler StreamHandler) (*anthropic.Message, error)
+}
+
+// StreamHandler receives a response as it streams
+type StreamHandler interface {
+	// Text is called with each piece of text as it arrives
+	Text(delta string)
+	// TextDone is called when a text block ends, with the sources it cites
+	TextDone(citations []anthropic.TextCitationUnion)
+	// ServerToolStart is called when the model starts calling a server tool
+	ServerToolStart()
+	// ThinkingDone is called when a thinking block ends, with its reasoning
+	ThinkingDone(thinking string)
+}
+
+// StatusError is an error response from a provider's HTTP API
+type StatusError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// errorStatus returns the HTTP status and headers of an API error response from any provider
+func errorStatus(err error) (int, http.Header, bool) {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		var header http.Header
+		if apiErr.Response != nil {
+			header = apiErr.Response.Header
+		}
+		return apiErr.StatusCode, header, true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, statusErr.Header, true
+	}
+	return 0, nil, false
+}
+
+// AnthropicProvider sends requests to Anthropic's Messages API
+type AnthropicProvider struct {
+	client anthropic.Client
+}
+
+// NewAnthropicProvider creates a provider using the given API key. The SDK's own retries
+// are off, since the service retries requests itself. The code execution beta is always
+// on, since stored conversations can hold its blocks where the tool is no longer offered.
+func NewAnthropicProvider(apiKey string) *AnthropicProvider {
+	return &AnthropicProvider{
+		client: anthropic.NewClient(
+			option.WithAPIKey(apiKey),
+			option.WithMaxRetries(0),
+			option.WithHeaderAdd("anthropic-beta", codeExecutionBeta),
+		),
+	}
+}
+
+// Name implements Provider
+func (p *AnthropicProvider) Name() string {
+	return "anthropic"
+}
+
+// CreateMessage implements Provider
+func (p *AnthropicProvider) CreateMessage(ctx context.Context, params anthropic.MessageNewParams) (*anthropic.Message, error) {
+	return p.client.Messages.New(ctx, params)
+}
+
+// StreamMessage implements Provider
+func (p *AnthropicProvider) StreamMessage(ctx context.Context, params anthropic.MessageNewParams, handler StreamHandler) (*anthropic.Message, error) {
+	stream := p.client.Messages.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	message := anthropic.Message{}
+	var startJSON string
+	for stream.Next() {
+		event := stream.Current()
+		if err := message.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("failed to accumulate stream event: %w", err)
+		}
+
+		switch event := event.AsAny().(type) {
+		case anthropic.ContentBlockStartEvent:
+			startJSON = event.ContentBlock.RawJSON()
+			if event.ContentBlock.Type == "server_tool_use" {
+				handler.ServerToolStart()
+			}
+		case anthropic.ContentBlockDeltaEvent:
+			if text, ok := event.Delta.AsAny().(anthropic.TextDelta); ok {
+				handler.Text(text.Text)
+			}
+		case anthropic.ContentBlockStopEvent:
+			// Citations are complete once their text block ends
+			switch block := &message.Content[len(message.Content)-1]; block.Type {
+			case "text":
+				handler.TextDone(block.Citations)
+			case "thinking":
+				handler.ThinkingDone(block.Thinking)
+			case "code_execution_tool_result":
+				// Accumulate re-encodes the block through fields that only fit web search
+				// results, losing the output. Result blocks arrive whole when they start,
+				// so the block is decoded again from that event.
+				if err := block.UnmarshalJSON([]byte(startJSON)); err != nil {
+					return nil, fmt.Errorf("failed to decode code execution result: %w", err)
+				}
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// RetryPolicy controls how transient API errors are retried. Delays grow exponentially
+// from BaseDelay up to MaxDelay, with jitter, unless the API says how long to wait.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// delay returns how long to wait before retry number attempt (from 0), and false when
+// the API asks for a longer wait than MaxDelay
+func (p RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	if wait, ok := retryAfter(err); ok {
+		return wait, wait <= p.MaxDelay
+	}
+
+	backoff := p.MaxDelay
+	if attempt < 32 {
+		backoff = min(p.BaseDelay<<attempt, p.MaxDelay)
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+	// Jitter keeps requests that failed together from retrying in lockstep
+	return backoff/2 + rand.N(backoff/2+1), true
+}
+
+// retryAfter reads the wait the API asked for from an error response's headers
+func retryAfter(err error) (time.Duration, bool) {
+	_, header, ok := errorStatus(err)
+	if !ok || header == nil {
+		return 0, false
+	}
+
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("retry-after")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// isTransient reports whether an error is worth retrying: rate limiting, overload, server
+// errors and dropped connections. Errors sent mid-stream aren't typed, so they are
+// recognized by their error type in the event payload.
+func isTransient(err error) bool {
+	if status, _, ok := errorStatus(err); ok {
+		switch status {
+		case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+			return true
+		}
+		return status >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	message := err.Error()
+	return strings.Contains(message, "overloaded_error") || strings.Contains(message, "api_error")
+}
+
+// sendWithRetry sends a request through the circuit breaker, retrying transient errors
+// with backoff. Overloaded requests aren't retried when the caller can fall back to
+// another model instead, nor are streamed requests once text has reached Discord.
+func (s *Service) sendWithRetry(ctx context.Context, params anthropic.MessageNewParams, reply *streamWriter, canFallBack bool) (*anthropic.Message, error) {
+	for attempt := 0; ; attempt++ {
+		if err := s.breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		var resp *anthropic.Message
+		var err error
+		written := 0
+		if reply != nil {
+			written = reply.written
+			resp, err = s.provider.StreamMessage(ctx, params, reply)
+		} else {
+			resp, err = s.provider.CreateMessage(ctx, params)
+		}
+		if err == nil {
+			s.breaker.Success()
+			return resp, nil
+		}
+		if ctx.Err() != nil || !isTransient(err) {
+			s.breaker.Release()
+			return nil, err
+		}
+		s.breaker.Failure(err)
+
+		if attempt >= s.retry.MaxRetries || (canFallBack && shouldFallBack(err)) || (reply != nil && reply.written != written) {
+			return nil, err
+		}
+		wait, ok := s.retry.delay(attempt, err)
+		if !ok {
+			return nil, err
+		}
+		s.logger.Warn("transient API error, retrying", "model", params.Model, "attempt", attempt+1, "wait", wait, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ModelRoute picks the models used for messages in a guild or channel
+type ModelRoute struct {
+	// Model answers long or technical requests
+	Model string `json:"model"`
+	// FastModel answers short chit-chat; setting it to Model turns routing off
+	FastModel string `json:"fastModel"`
+	// Fallbacks are tried in order when the chosen model is overloaded or unavailable
+	Fallbacks []string `json:"fallbacks"`
+	// RevealThinking shows Claude's reasoning in a "spoiler" before the answer or a follow-up
+	// "message" after it; empty or "off" keeps it hidden
+	RevealThinking string `json:"revealThinking"`
+}
+
+// merge returns the route with the fields set in override replacing its own
+func (r ModelRoute) merge(override *ModelRoute) ModelRoute {
+	if override == nil {
+		return r
+	}
+	if override.Model != "" {
+		r.Model = override.Model
+	}
+	if override.FastModel != "" {
+		r.FastModel = override.FastModel
+	}
+	if override.Fallbacks != nil {
+		r.Fallbacks = override.Fallbacks
+	}
+	if override.RevealThinking != "" {
+		r.RevealThinking = override.RevealThinking
+	}
+	return r
+}
+
+// ModelRoutes holds the model overrides for each guild and channel. Routes are layered
+// field by field: the channel's fields win over the guild's, which win over the default.
+// Thinking sets the extended thinking budget of models by ID prefix.
+type ModelRoutes struct {
+	Default  *ModelRoute            `json:"default"`
+	Guilds   map[string]*ModelRoute `json:"guilds"`
+	Channels map[string]*ModelRoute `json:"channels"`
+	Thinking map[string]int         `json:"thinking"`
+}
+
+// LoadModelRoutes reads model overrides from a JSON file. An empty path uses the configured
+// models everywhere.
+func LoadModelRoutes(filename string) (*ModelRoutes, error) {
+	routes := &ModelRoutes{}
+	if filename == "" {
+		return routes, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model routes file: %w", err)
+	}
+	if err := json.Unmarshal(data, routes); err != nil {
+		return nil, fmt.Errorf("failed to parse model routes file: %w", err)
+	}
+
+	return routes, nil
+}
+
+// ModelRouter chooses which model answers a message
+type ModelRouter struct {
+	defaults     ModelRoute
+	routes       *ModelRoutes
+	chatMaxChars int
+}
+
+// NewModelRouter creates a router using the defaults wherever routes don't override them.
+// Messages up to chatMaxChars long that don't look technical go to the fast model.
+func NewModelRouter(defaults ModelRoute, routes *ModelRoutes, chatMaxChars int) *ModelRouter {
+	return &ModelRouter{defaults: defaults, routes: routes, chatMaxChars: chatMaxChars}
+}
+
+// For returns the route that applies to a message from the given guild and channel
+func (r *ModelRouter) For(guildID, channelID string) ModelRoute {
+	route := r.defaults
+	if r.routes == nil {
+		return route
+	}
+	route = route.merge(r.routes.Default)
+	if guildID != "" {
+		route = route.merge(r.routes.Guilds[guildID])
+	}
+	return route.merge(r.routes.Channels[channelID])
+}
+
+// Route returns the models to try for a message in order: the chosen model, then its
+// fallbacks
+func (r *ModelRouter) Route(message *discordgo.Message) []string {
+	route := r.For(message.GuildID, message.ChannelID)
+
+	model := route.Model
+	if route.FastModel != "" && r.isChitChat(message) {
+		model = route.FastModel
+	}
+
+	models := []string{model}
+	for _, fallback := range route.Fallbacks {
+		if fallback != "" && !slices.Contains(models, fallback) {
+			models = append(models, fallback)
+		}
+	}
+	return models
+}
+
+// ThinkingBudget returns the extended thinking budget set for the model whose ID prefix
+// matches longest, if any
+func (r *ModelRouter) ThinkingBudget(model string) (int, bool) {
+	if r.routes == nil {
+		return 0, false
+	}
+	var best string
+	found := false
+	for prefix := range r.routes.Thinking {
+		if strings.HasPrefix(model, prefix) && (!found || len(prefix) > len(best)) {
+			best, found = prefix, true
+		}
+	}
+	return r.routes.Thinking[best], found
+}
+
+// technicalWords mark a message as needing the stronger model even when it's short
+var technicalWords = map[string]bool{
+	"algorithm": true, "analyze": true, "analyse": true, "api": true, "bug": true,
+	"calculate": true, "code": true, "compile": true, "config": true, "debug": true,
+	"deploy": true, "derive": true, "error": true, "exception": true, "explain": true,
+	"function": true, "implement": true, "install": true, "javascript": true, "json": true,
+	"kubernetes": true, "math": true, "optimize": true, "prove": true, "python": true,
+	"query": true, "refactor": true, "regex": true, "rust": true, "script": true,
+	"sql": true, "stacktrace": true, "summarize": true, "translate": true, "typescript": true,
+}
+
+// isChitChat reports whether a message is short, casual conversation: no attachments, code,
+// links or technical vocabulary
+func (r *ModelRouter) isChitChat(message *discordgo.Message) bool {
+	content := message.Content
+	if len([]rune(content)) > r.chatMaxChars || len(message.Attachments) > 0 {
+		return false
+	}
+	if strings.Contains(content, "`") || strings.Contains(content, "http://") || strings.Contains(content, "https://") {
+		return false
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(content), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	for _, word := range words {
+		if technicalWords[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// shouldFallBack reports whether a failed request should be retried with the next model:
+// the model is overloaded or Anthropic doesn't know it. Overload errors sent mid-stream
+// aren't typed, so they are recognized by their error type in the event payload.
+func shouldFallBack(err error) bool {
+	if isModelNotFound(err) {
+		return true
+	}
+	if status, _, ok := errorStatus(err); ok {
+		return status == 529
+	}
+	return strings.Contains(err.Error(), "overloaded_error")
+}
+
+// isModelNotFound reports whether err is Anthropic's answer to a model ID it doesn't know: a
+// 404 not_found_error whose message names the model. Other 404s, such as a wrong base URL
+// or a missing file, would fail the same way with any model.
+func isModelNotFound(err error) bool {
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return false
+	}
+	var body struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(apiErr.RawJSON()), &body); err != nil {
+		return false
+	}
+	return body.Error.Type == "not_found_error" && strings.HasPrefix(body.Error.Message, "model:")
+}
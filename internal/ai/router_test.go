@@ -0,0 +1,158 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestIsChitChat(t *testing.T) {
+	router := NewModelRouter(ModelRoute{}, nil, 40)
+
+	tests := []struct {
+		name        string
+		content     string
+		attachments int
+		want        bool
+	}{
+		{name: "greeting", content: "hey, how's it going?", want: true},
+		{name: "empty", content: "", want: true},
+		{name: "at the length limit", content: strings.Repeat("a", 40), want: true},
+		{name: "too long", content: strings.Repeat("a", 41), want: false},
+		{name: "length counted in characters", content: strings.Repeat("é", 40), want: true},
+		{name: "attachment", content: "look at this", attachments: 1, want: false},
+		{name: "inline code", content: "what does `x` do", want: false},
+		{name: "link", content: "seen https://go.dev?", want: false},
+		{name: "technical word", content: "can you debug this", want: false},
+		{name: "technical word in any case", content: "SQL or not?", want: false},
+		{name: "technical word inside another word", content: "that was a codeword", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := &discordgo.Message{Content: tt.content}
+			for range tt.attachments {
+				message.Attachments = append(message.Attachments, &discordgo.MessageAttachment{})
+			}
+			if got := router.isChitChat(message); got != tt.want {
+				t.Errorf("isChitChat(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelRouterRoute(t *testing.T) {
+	defaults := ModelRoute{Model: "big", FastModel: "small", Fallbacks: []string{"backup", "big", ""}}
+	routes := &ModelRoutes{
+		Default: &ModelRoute{Fallbacks: []string{"backup", "small"}},
+		Guilds: map[string]*ModelRoute{
+			"guild": {Model: "guild-big"},
+			"plain": {FastModel: "big"},
+		},
+		Channels: map[string]*ModelRoute{
+			"channel": {FastModel: "channel-small", Fallbacks: []string{}},
+		},
+	}
+	router := NewModelRouter(defaults, routes, 40)
+
+	tests := []struct {
+		name      string
+		guildID   string
+		channelID string
+		content   string
+		want      []string
+	}{
+		{name: "chat goes to the fast model", content: "hi", want: []string{"small", "backup"}},
+		{name: "technical goes to the model", content: "explain this", want: []string{"big", "backup", "small"}},
+		{name: "guild model", guildID: "guild", content: "explain this", want: []string{"guild-big", "backup", "small"}},
+		{name: "channel wins over guild", guildID: "guild", channelID: "channel", content: "hi", want: []string{"channel-small"}},
+		{name: "fast model equal to model", guildID: "plain", content: "hi", want: []string{"big", "backup", "small"}},
+		{name: "DM uses the default", channelID: "dm", content: "hi", want: []string{"small", "backup"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := &discordgo.Message{GuildID: tt.guildID, ChannelID: tt.channelID, Content: tt.content}
+			if got := router.Route(message); !slices.Equal(got, tt.want) {
+				t.Errorf("Route = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := NewModelRouter(defaults, nil, 40).Route(&discordgo.Message{Content: "hi"}); !slices.Equal(got, []string{"small", "backup", "big"}) {
+		t.Errorf("Route without routes = %v, want the defaults", got)
+	}
+}
+
+// anthropicError builds the error the SDK returns for an API error response
+func anthropicError(t *testing.T, status int, body string) error {
+	t.Helper()
+	apiErr := &anthropic.Error{StatusCode: status}
+	if err := apiErr.UnmarshalJSON([]byte(body)); err != nil {
+		t.Fatalf("bad error body: %v", err)
+	}
+	return apiErr
+}
+
+func TestShouldFallBack(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "overloaded",
+			err:  anthropicError(t, 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`),
+			want: true,
+		},
+		{
+			name: "unknown model",
+			err:  anthropicError(t, 404, `{"type":"error","error":{"type":"not_found_error","message":"model: claude-nonexistent"}}`),
+			want: true,
+		},
+		{
+			name: "unknown model, wrapped",
+			err:  fmt.Errorf("request failed: %w", anthropicError(t, 404, `{"type":"error","error":{"type":"not_found_error","message":"model: claude-nonexistent"}}`)),
+			want: true,
+		},
+		{
+			name: "other not found",
+			err:  anthropicError(t, 404, `{"type":"error","error":{"type":"not_found_error","message":"Not found"}}`),
+		},
+		{
+			name: "not found without a body",
+			err:  anthropicError(t, 404, `{}`),
+		},
+		{
+			name: "OpenAI-compatible not found",
+			err:  &StatusError{StatusCode: 404, Body: `{"error":{"message":"model: llama3"}}`},
+		},
+		{
+			name: "rate limited",
+			err:  anthropicError(t, 429, `{"type":"error","error":{"type":"rate_limit_error","message":"Slow down"}}`),
+		},
+		{
+			name: "bad request",
+			err:  anthropicError(t, 400, `{"type":"error","error":{"type":"invalid_request_error","message":"model: bad"}}`),
+		},
+		{
+			name: "overloaded mid-stream",
+			err:  errors.New(`received error while streaming: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`),
+			want: true,
+		},
+		{name: "network error", err: errors.New("connection reset by peer")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldFallBack(tt.err); got != tt.want {
+				t.Errorf("shouldFallBack = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jsonSchema is the subset of JSON Schema used to describe and validate tool input
+type jsonSchema struct {
+	Type        string                 `json:"type"`
+	Description string                 `json:"description,omitempty"`
+	Enum        []any                  `json:"enum,omitempty"`
+	Default     any                    `json:"default,omitempty"`
+	Items       *jsonSchema            `json:"items,omitempty"`
+	Properties  map[string]*jsonSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+}
+
+// schemaFor generates the JSON Schema for a Go type. Struct fields are described with tags:
+//
+//	Unit string `json:"unit" description:"The temperature unit" enum:"celsius,fahrenheit" default:"celsius"`
+//	Query string `json:"query" description:"The search query" required:"true"`
+func schemaFor(t reflect.Type) (*jsonSchema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &jsonSchema{Type: "string"}, nil
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		return &jsonSchema{Type: "object"}, nil
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// structSchema generates an object schema from a struct's exported fields and their tags
+func structSchema(t reflect.Type) (*jsonSchema, error) {
+	schema := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := schemaFor(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		property.Description = field.Tag.Get("description")
+
+		if enum := field.Tag.Get("enum"); enum != "" {
+			for _, value := range strings.Split(enum, ",") {
+				parsed, err := parseTagValue(property.Type, strings.TrimSpace(value))
+				if err != nil {
+					return nil, fmt.Errorf("field %s: invalid enum value: %w", field.Name, err)
+				}
+				property.Enum = append(property.Enum, parsed)
+			}
+		}
+		if value, ok := field.Tag.Lookup("default"); ok {
+			parsed, err := parseTagValue(property.Type, value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: invalid default: %w", field.Name, err)
+			}
+			property.Default = parsed
+		}
+		if required, _ := strconv.ParseBool(field.Tag.Get("required")); required {
+			schema.Required = append(schema.Required, name)
+		}
+
+		schema.Properties[name] = property
+	}
+
+	return schema, nil
+}
+
+// parseTagValue parses an enum or default tag value as the given schema type
+func parseTagValue(schemaType, value string) (any, error) {
+	switch schemaType {
+	case "string":
+		return value, nil
+	case "boolean":
+		return strconv.ParseBool(value)
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	}
+	return nil, fmt.Errorf("tag values are not supported for %s fields", schemaType)
+}
+
+// validate checks decoded JSON against the schema and fills in defaults for missing properties
+func (s *jsonSchema) validate(value any, path string) error {
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return typeError(path, s.Type, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("missing required parameter %q", joinPath(path, name))
+			}
+		}
+		for name, property := range object {
+			// Optional parameters may be sent as null, which is treated as leaving them out
+			if property == nil {
+				if slices.Contains(s.Required, name) {
+					return fmt.Errorf("parameter %q is required", joinPath(path, name))
+				}
+				delete(object, name)
+				continue
+			}
+			schema, ok := s.Properties[name]
+			if !ok {
+				if s.Properties == nil {
+					continue // free-form object
+				}
+				return fmt.Errorf("unknown parameter %q", joinPath(path, name))
+			}
+			if err := schema.validate(property, joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+		for name, schema := range s.Properties {
+			if _, ok := object[name]; !ok && schema.Default != nil {
+				object[name] = schema.Default
+			}
+		}
+		return nil
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return typeError(path, s.Type, value)
+		}
+		for i, item := range array {
+			if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return typeError(path, s.Type, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(path, s.Type, value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return typeError(path, s.Type, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return typeError(path, s.Type, value)
+		}
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(allowed any) bool {
+		return fmt.Sprint(allowed) == fmt.Sprint(value)
+	}) {
+		return fmt.Errorf("parameter %q must be one of %v", path, s.Enum)
+	}
+
+	return nil
+}
+
+// typeError describes a value that doesn't match the schema type
+func typeError(path, want string, value any) error {
+	if path == "" {
+		return fmt.Errorf("input must be of type %s, got %T", want, value)
+	}
+	return fmt.Errorf("parameter %q must be of type %s, got %T", path, want, value)
+}
+
+// joinPath appends a property name to a parameter path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
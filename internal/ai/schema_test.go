@@ -0,0 +1,224 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+type schemaTestInput struct {
+	Location string   `json:"location" description:"City name" required:"true"`
+	Unit     string   `json:"unit" enum:"celsius,fahrenheit" default:"celsius"`
+	Days     int      `json:"days" default:"1"`
+	Tags     []string `json:"tags"`
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := schemaFor(reflect.TypeFor[schemaTestInput]())
+	if err != nil {
+		t.Fatalf("schemaFor: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+		want    map[string]any
+	}{
+		{
+			name:  "defaults filled in",
+			input: `{"location":"Paris"}`,
+			want:  map[string]any{"location": "Paris", "unit": "celsius", "days": int64(1)},
+		},
+		{
+			name:  "optional null treated as missing",
+			input: `{"location":"Paris","unit":null}`,
+			want:  map[string]any{"location": "Paris", "unit": "celsius", "days": int64(1)},
+		},
+		{name: "required null", input: `{"location":null}`, wantErr: `parameter "location" is required`},
+		{name: "required missing", input: `{}`, wantErr: `missing required parameter "location"`},
+		{name: "top-level null", input: `null`, wantErr: "input must be of type object"},
+		{name: "top-level array", input: `[]`, wantErr: "input must be of type object"},
+		{name: "wrong type", input: `{"location":3}`, wantErr: `parameter "location" must be of type string`},
+		{name: "not in enum", input: `{"location":"Paris","unit":"kelvin"}`, wantErr: `parameter "unit" must be one of`},
+		{name: "fractional integer", input: `{"location":"Paris","days":1.5}`, wantErr: `parameter "days" must be of type integer`},
+		{name: "null array item", input: `{"location":"Paris","tags":[null]}`, wantErr: `parameter "tags[0]" must be of type string`},
+		{name: "unknown parameter", input: `{"location":"Paris","extra":1}`, wantErr: `unknown parameter "extra"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value any
+			if err := json.Unmarshal([]byte(tt.input), &value); err != nil {
+				t.Fatalf("bad test input: %v", err)
+			}
+
+			err := schema.validate(value, "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validate(%s) error = %v, want %q", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate(%s) error = %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("validate(%s) left %#v, want %#v", tt.input, value, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteToolRejectsNullInput(t *testing.T) {
+	var got string
+	tool := NewTool("echo", "Echoes the location", func(_ context.Context, params schemaTestInput) (string, error) {
+		got = params.Location
+		return got, nil
+	})
+	registry, err := NewToolRegistry(tool)
+	if err != nil {
+		t.Fatalf("NewToolRegistry: %v", err)
+	}
+
+	for _, input := range []string{`null`, `{"location":null}`, `"{\"location\": Paris"`, `[]`} {
+		if _, err := registry.executeTool(context.Background(), "echo", json.RawMessage(input), nil); err == nil {
+			t.Errorf("executeTool(%s) succeeded, want an error", input)
+		}
+	}
+	if got != "" {
+		t.Errorf("tool ran with location %q", got)
+	}
+}
+
+// newTestRegistry creates a registry of tools that take no input and time out after timeout
+func newTestRegistry(t *testing.T, timeout time.Duration, tools map[string]func(ctx context.Context) (string, error)) *ToolRegistry {
+	t.Helper()
+	registry, err := NewToolRegistry()
+	if err != nil {
+		t.Fatalf("NewToolRegistry: %v", err)
+	}
+	for name, execute := range tools {
+		tool := NewTool(name, "Test tool", func(ctx context.Context, _ struct{}) (string, error) {
+			return execute(ctx)
+		})
+		tool.Timeout = timeout
+		if err := registry.Register(tool); err != nil {
+			t.Fatalf("Register(%s): %v", name, err)
+		}
+	}
+	return registry
+}
+
+func TestExecuteToolsRunsInParallelInOrder(t *testing.T) {
+	// Each tool waits for the other to start, so run one at a time they would time out
+	var started sync.WaitGroup
+	started.Add(2)
+	both := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(both)
+	}()
+	waitForOther := func(result string) func(ctx context.Context) (string, error) {
+		return func(ctx context.Context) (string, error) {
+			started.Done()
+			select {
+			case <-both:
+				return result, nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+	}
+	registry := newTestRegistry(t, time.Second, map[string]func(ctx context.Context) (string, error){
+		"first":  waitForOther("one"),
+		"second": waitForOther("two"),
+	})
+
+	toolUses := []anthropic.ToolUseBlock{
+		{ID: "toolu_1", Name: "second", Input: json.RawMessage(`{}`)},
+		{ID: "toolu_2", Name: "missing", Input: json.RawMessage(`{}`)},
+		{ID: "toolu_3", Name: "first", Input: json.RawMessage(`{}`)},
+	}
+	blocks, errs := registry.ExecuteTools(context.Background(), toolUses, nil)
+
+	want := []struct {
+		id      string
+		text    string
+		isError bool
+	}{
+		{id: "toolu_1", text: "two"},
+		{id: "toolu_2", text: "Error: unknown tool: missing", isError: true},
+		{id: "toolu_3", text: "one"},
+	}
+	for i, w := range want {
+		result := blocks[i].OfToolResult
+		if result == nil {
+			t.Fatalf("block %d isn't a tool result", i)
+		}
+		text := result.Content[0].OfText.Text
+		if result.ToolUseID != w.id || text != w.text || result.IsError.Value != w.isError {
+			t.Errorf("block %d = %s %q (error %v), want %s %q (error %v)",
+				i, result.ToolUseID, text, result.IsError.Value, w.id, w.text, w.isError)
+		}
+		if (errs[i] != nil) != w.isError {
+			t.Errorf("errs[%d] = %v", i, errs[i])
+		}
+	}
+}
+
+func TestExecuteToolTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	registry := newTestRegistry(t, 20*time.Millisecond, map[string]func(ctx context.Context) (string, error){
+		"respects_ctx": func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+		"ignores_ctx": func(context.Context) (string, error) {
+			<-release
+			return "too late", nil
+		},
+	})
+	for _, name := range []string{"respects_ctx", "ignores_ctx"} {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			_, err := registry.executeTool(context.Background(), name, json.RawMessage(`{}`), nil)
+			if err == nil || !strings.Contains(err.Error(), "timed out after 20ms") {
+				t.Errorf("executeTool error = %v, want a timeout", err)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("executeTool returned after %s, want about the 20ms timeout", elapsed)
+			}
+		})
+	}
+}
+
+func TestExecuteToolParentCancelled(t *testing.T) {
+	started := make(chan struct{})
+	registry := newTestRegistry(t, time.Second, map[string]func(ctx context.Context) (string, error){
+		"wait": func(ctx context.Context) (string, error) {
+			close(started)
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := registry.executeTool(ctx, "wait", json.RawMessage(`{}`), nil)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "was cancelled") {
+		t.Errorf("executeTool error = %v, want it cancelled", err)
+	}
+}
@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"encoding/json"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
+	"github.com/anthropics/anthropic-sdk-go/shared/constant"
+)
+
+const (
+	// webSearchToolName is the name of Anthropic's web search server tool
+	webSearchToolName = "web_search"
+	// codeExecutionToolName is the name of Anthropic's code execution server tool
+	codeExecutionToolName = "code_execution"
+	// codeExecutionBeta is the beta header value the code execution tool requires
+	codeExecutionBeta = "code-execution-2025-05-22"
+)
+
+// ServerTool is a tool Anthropic runs on its side, such as web search. Claude calls it and
+// gets its results within a single response, so the bot only has to offer it and keep its
+// blocks in the conversation history.
+type ServerTool struct {
+	Name  string
+	Param anthropic.ToolUnionParam
+}
+
+// NewWebSearchTool creates the web_search server tool, allowing at most maxUses searches per
+// response (0 means no limit)
+func NewWebSearchTool(maxUses int) *ServerTool {
+	webSearch := &anthropic.WebSearchTool20250305Param{}
+	if maxUses > 0 {
+		webSearch.MaxUses = anthropic.Int(int64(maxUses))
+	}
+	return &ServerTool{
+		Name:  webSearchToolName,
+		Param: anthropic.ToolUnionParam{OfWebSearchTool20250305: webSearch},
+	}
+}
+
+// NewCodeExecutionTool creates the code_execution server tool, which runs Python in a
+// sandbox on Anthropic's side. The SDK only models it in the beta Messages API, so the tool
+// is sent as raw JSON, and AnthropicProvider sends the beta header it requires.
+func NewCodeExecutionTool() *ServerTool {
+	return &ServerTool{
+		Name: codeExecutionToolName,
+		Param: param.Override[anthropic.ToolUnionParam](map[string]any{
+			"type": "code_execution_20250522",
+			"name": codeExecutionToolName,
+		}),
+	}
+}
+
+// assistantMessage converts a response into a message for the conversation history.
+// Message.ToParam drops server tool blocks and web search citations, which the API needs
+// back unchanged, so those are converted here. The block fields are used rather than the
+// As* accessors because a streamed message's raw block JSON doesn't round-trip. Code
+// execution results aren't modelled by the SDK at all, so they are sent back as the raw
+// JSON of the block (see AnthropicProvider.StreamMessage).
+func assistantMessage(resp *anthropic.Message) anthropic.MessageParam {
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(resp.Content))
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			blocks = append(blocks, anthropic.ContentBlockParamUnion{OfText: textBlockParam(block)})
+		case "server_tool_use":
+			// The name's type is only declared for web_search, but it holds any tool's name
+			blocks = append(blocks, anthropic.ContentBlockParamUnion{OfServerToolUse: &anthropic.ServerToolUseBlockParam{
+				ID:    block.ID,
+				Input: block.Input,
+				Name:  constant.WebSearch(block.Name),
+			}})
+		case "web_search_tool_result":
+			blocks = append(blocks, anthropic.ContentBlockParamUnion{OfWebSearchToolResult: webSearchResultParam(block)})
+		case "code_execution_tool_result":
+			blocks = append(blocks, param.Override[anthropic.ContentBlockParamUnion](json.RawMessage(block.RawJSON())))
+		case "thinking", "redacted_thinking":
+			blocks = append(blocks, thinkingParam(block))
+		default:
+			blocks = append(blocks, block.ToParam())
+		}
+	}
+	return anthropic.NewAssistantMessage(blocks...)
+}
+
+// textBlockParam converts a text block, keeping its web search citations
+func textBlockParam(block anthropic.ContentBlockUnion) *anthropic.TextBlockParam {
+	p := &anthropic.TextBlockParam{Text: block.Text}
+	for _, citation := range block.Citations {
+		if citation.Type != "web_search_result_location" {
+			continue
+		}
+		p.Citations = append(p.Citations, anthropic.TextCitationParamUnion{OfWebSearchResultLocation: &anthropic.CitationWebSearchResultLocationParam{
+			Title:          anthropic.String(citation.Title),
+			CitedText:      citation.CitedText,
+			EncryptedIndex: citation.EncryptedIndex,
+			URL:            citation.URL,
+		}})
+	}
+	return p
+}
+
+// webSearchResultParam converts a web search result block, or the error it reports
+func webSearchResultParam(block anthropic.ContentBlockUnion) *anthropic.WebSearchToolResultBlockParam {
+	p := &anthropic.WebSearchToolResultBlockParam{ToolUseID: block.ToolUseID}
+
+	if block.Content.ErrorCode != "" {
+		p.Content.OfRequestWebSearchToolResultError = &anthropic.WebSearchToolRequestErrorParam{
+			ErrorCode: anthropic.WebSearchToolRequestErrorErrorCode(block.Content.ErrorCode),
+		}
+		return p
+	}
+
+	items := make([]anthropic.WebSearchResultBlockParam, 0, len(block.Content.OfWebSearchResultBlockArray))
+	for _, item := range block.Content.OfWebSearchResultBlockArray {
+		itemParam := anthropic.WebSearchResultBlockParam{
+			EncryptedContent: item.EncryptedContent,
+			Title:            item.Title,
+			URL:              item.URL,
+		}
+		if item.PageAge != "" {
+			itemParam.PageAge = anthropic.String(item.PageAge)
+		}
+		items = append(items, itemParam)
+	}
+	p.Content.OfWebSearchToolResultBlockItem = items
+	return p
+}
@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+func TestAnthropicProviderStreamsCodeExecution(t *testing.T) {
+	result := `{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"code_execution_result","stdout":"4\n","stderr":"","return_code":0,"content":[]}}`
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"server_tool_use","id":"srvtoolu_1","name":"code_execution","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"code\": \"print(2 + 2)\"}"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":` + result + `}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"It's 4."}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":20}}`,
+		`{"type":"message_stop"}`,
+	}
+
+	var beta string
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		beta = r.Header.Get("anthropic-beta")
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			var e struct{ Type string }
+			json.Unmarshal([]byte(event), &e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, event)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("ANTHROPIC_BASE_URL", server.URL)
+	provider := NewAnthropicProvider("secret")
+
+	params := anthropic.MessageNewParams{
+		Model:     "claude-sonnet-4-5",
+		MaxTokens: 500,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("What's 2 + 2?"))},
+		Tools:     []anthropic.ToolUnionParam{NewCodeExecutionTool().Param},
+	}
+	addCacheBreakpoints(&params)
+
+	handler := &recordingHandler{}
+	resp, err := provider.StreamMessage(context.Background(), params, handler)
+	if err != nil {
+		t.Fatalf("StreamMessage: %v", err)
+	}
+
+	if beta != codeExecutionBeta {
+		t.Errorf("anthropic-beta header = %q, want %q", beta, codeExecutionBeta)
+	}
+	tools, _ := json.Marshal(request["tools"])
+	if want := `[{"cache_control":{"type":"ephemeral"},"name":"code_execution","type":"code_execution_20250522"}]`; string(tools) != want {
+		t.Errorf("tools = %s, want %s", tools, want)
+	}
+	if got := handler.text.String(); got != "It's 4." {
+		t.Errorf("streamed text = %q", got)
+	}
+
+	message, err := json.Marshal(assistantMessage(resp))
+	if err != nil {
+		t.Fatalf("failed to encode history message: %v", err)
+	}
+	for _, want := range []string{
+		`{"id":"srvtoolu_1","input":{"code":"print(2 + 2)"},"name":"code_execution","type":"server_tool_use"}`,
+		result,
+		`{"text":"It's 4.","type":"text"}`,
+	} {
+		if !strings.Contains(string(message), want) {
+			t.Errorf("history message %s\nis missing %s", message, want)
+		}
+	}
+}
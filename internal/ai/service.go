@@ -1,129 +1,522 @@
 package ai
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/bwmarrin/discordgo"
 	"github.com/charmbracelet/log"
+
+	"discord-assist/internal/config"
+	"discord-assist/internal/conversation"
+	"discord-assist/internal/usage"
 )
 
-// Service handles AI interactions using Anthropic's Claude API
+// Messenger posts and updates Discord messages on behalf of the AI service
+type Messenger interface {
+	PostMessage(channelID, content string) (*discordgo.Message, error)
+	EditMessage(channelID, messageID, content string) error
+	DeleteMessage(channelID, messageID string) error
+	GetRecentMessages(channelID string, limit int) ([]*discordgo.Message, error)
+	GetMessagesBefore(channelID, beforeID string, limit int) ([]*discordgo.Message, error)
+	Channel(channelID string) (*discordgo.Channel, error)
+	Guild(guildID string) (*discordgo.Guild, error)
+	UserID() string
+	UserName() string
+}
+
+// Service handles AI interactions with Claude, or another model behind a Provider
 type Service struct {
-	client             *anthropic.Client
-	logger             *log.Logger
-	model              string
-	toolRegistry       *ToolRegistry
-	defaultParams      anthropic.MessageNewParams
-	sendDiscordMessage func(channelID, content string)
+	provider         Provider
+	logger           *log.Logger
+	router           *ModelRouter
+	toolRegistry     *ToolRegistry
+	toolPolicies     *ToolPolicies
+	personas         *Personas
+	thinkingTokens   int
+	revealThinking   string
+	maxContinuations int
+	defaultParams    anthropic.MessageNewParams
+	messenger        Messenger
+	store            conversation.Store
+	ledger           usage.Ledger
+	pricing          *usage.Pricing
+	unpricedModels   sync.Map // models already warned about having no price
+	quotas           usage.Quotas
+	breaker          *CircuitBreaker
+	retry            RetryPolicy
+	channelLocks     sync.Map
+	toolErrors       sync.Map // channel ID -> consecutive failed tool calls, kept between requests
+	httpClient       *http.Client
+	streaming        bool
+	promptCaching    bool
+	contextTokens    int
+	summaryModel     string
+	editInterval     time.Duration
+	maxImages        int
+	maxImageBytes    int
+	maxTextBytes     int
+	maxPDFBytes      int
+	weather          WeatherProvider
+	search           SearchProvider
+	fetcher          *URLFetcher
+	budget           Budget
 }
 
 // NewService creates a new AI service
-func NewService(apiKey, model string, logger *log.Logger, sendDiscordMessage func(channelID, content string)) (*Service, error) {
-	client := anthropic.NewClient(option.WithAPIKey(apiKey))
+func NewService(cfg *config.Config, logger *log.Logger, messenger Messenger, store conversation.Store, ledger usage.Ledger) (*Service, error) {
+	var provider Provider
+	switch cfg.LLM.Provider {
+	case "openai":
+		provider = NewOpenAIProvider(cfg.OpenAI.BaseURL, cfg.OpenAI.APIKey, cfg.OpenAI.Model)
+	default:
+		provider = NewAnthropicProvider(cfg.Anthropic.APIKey)
+	}
+
+	toolPolicies, err := LoadToolPolicies(cfg.Tools.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	personas, err := LoadPersonas(cfg.Personas.Dir, cfg.Personas.ReloadInterval, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	modelRoutes, err := LoadModelRoutes(cfg.Models.RoutesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pricing, err := usage.LoadPricing(cfg.Usage.PricingFile)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create default parameters using proper SDK types
+	// Create default parameters using proper SDK types; the model and system prompt are chosen per request
 	defaultParams := anthropic.MessageNewParams{
-		Model:       anthropic.Model(model),
-		MaxTokens:   1000,
-		System:      []anthropic.TextBlockParam{{Text: SystemPrompt}},
+		MaxTokens:   int64(cfg.Anthropic.MaxTokens),
 		Temperature: anthropic.Float(0.7),
-		Tools:       GetTools(),
 	}
 
-	return &Service{
-		client:             &client,
-		logger:             logger,
-		model:              model,
-		toolRegistry:       GlobalToolRegistry,
-		defaultParams:      defaultParams,
-		sendDiscordMessage: sendDiscordMessage,
-	}, nil
+	s := &Service{
+		provider: provider,
+		logger:   logger,
+		router: NewModelRouter(ModelRoute{
+			Model:     cfg.Anthropic.Model,
+			FastModel: cfg.Models.FastModel,
+			Fallbacks: cfg.Models.Fallbacks,
+		}, modelRoutes, cfg.Models.ChatMaxChars),
+		toolPolicies:     toolPolicies,
+		personas:         personas,
+		thinkingTokens:   cfg.Thinking.BudgetTokens,
+		revealThinking:   cfg.Thinking.Reveal,
+		maxContinuations: cfg.Anthropic.MaxContinuations,
+		defaultParams:    defaultParams,
+		messenger:        messenger,
+		store:            store,
+		ledger:           ledger,
+		pricing:          pricing,
+		quotas: usage.Quotas{
+			GuildDaily:   cfg.Usage.GuildDailyLimit,
+			GuildMonthly: cfg.Usage.GuildMonthlyLimit,
+			UserDaily:    cfg.Usage.UserDailyLimit,
+			UserMonthly:  cfg.Usage.UserMonthlyLimit,
+		},
+		breaker: NewCircuitBreaker(cfg.Retry.BreakerThreshold, cfg.Retry.BreakerCooldown),
+		retry: RetryPolicy{
+			MaxRetries: cfg.Retry.MaxRetries,
+			BaseDelay:  cfg.Retry.BaseDelay,
+			MaxDelay:   cfg.Retry.MaxDelay,
+		},
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		streaming:     cfg.Streaming.Enabled,
+		promptCaching: cfg.Anthropic.PromptCaching,
+		contextTokens: cfg.Context.MaxTokens,
+		summaryModel:  cfg.Context.SummaryModel,
+		editInterval:  cfg.Streaming.EditInterval,
+		maxImages:     cfg.Attachments.MaxImages,
+		maxImageBytes: cfg.Attachments.MaxImageBytes,
+		maxTextBytes:  cfg.Attachments.MaxTextBytes,
+		maxPDFBytes:   cfg.Attachments.MaxPDFBytes,
+		weather:       NewOpenMeteoProvider(cfg.Weather.GeocodingURL, cfg.Weather.ForecastURL, cfg.Weather.CacheTTL),
+		fetcher: NewURLFetcher(URLFetcherConfig{
+			AllowedHosts: cfg.Fetch.AllowedHosts,
+			DeniedHosts:  cfg.Fetch.DeniedHosts,
+			MaxBytes:     cfg.Fetch.MaxBytes,
+			Timeout:      cfg.Fetch.Timeout,
+			MaxTokens:    cfg.Fetch.MaxTokens,
+		}),
+		budget: Budget{
+			MaxToolRounds:   cfg.Budget.MaxToolRounds,
+			MaxToolErrors:   cfg.Budget.MaxToolErrors,
+			MaxInputTokens:  int64(cfg.Budget.MaxInputTokens),
+			MaxOutputTokens: int64(cfg.Budget.MaxOutputTokens),
+			Timeout:         cfg.Budget.Timeout,
+		},
+	}
+
+	if cfg.Search.URL != "" {
+		s.search = NewSearxNGProvider(cfg.Search.URL, cfg.Search.MaxResults)
+	}
+
+	toolRegistry, err := NewToolRegistry(s.builtinTools()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool registry: %w", err)
+	}
+	for _, tool := range []*ServerTool{NewWebSearchTool(cfg.ServerTools.WebSearchMaxUses), NewCodeExecutionTool()} {
+		if err := toolRegistry.RegisterServerTool(tool); err != nil {
+			return nil, fmt.Errorf("failed to create tool registry: %w", err)
+		}
+	}
+	s.toolRegistry = toolRegistry
+
+	return s, nil
+}
+
+// ToolRegistry returns the service's tool registry, for registering additional tools
+func (s *Service) ToolRegistry() *ToolRegistry {
+	return s.toolRegistry
+}
+
+// Breaker returns the circuit breaker guarding calls to the provider's API, for watching
+// its state
+func (s *Service) Breaker() *CircuitBreaker {
+	return s.breaker
+}
+
+// request carries the per-message context of a single GenerateResponse call
+type request struct {
+	guildID    string
+	channelID  string
+	userID     string
+	models     []string // the routed model, then its fallbacks
+	system     string   // the rendered persona
+	toolPolicy *ToolPolicy
+	thinking   int64  // extended thinking budget, 0 when off
+	reveal     string // how reasoning is shown: off, spoiler or message
 }
 
-// createMessageParams creates MessageNewParams with default values and custom messages
-func (s *Service) createMessageParams(messages []anthropic.MessageParam) anthropic.MessageNewParams {
+// createMessageParams creates MessageNewParams with default values, the request's persona,
+// the tools permitted for the request and custom messages, with prompt caching breakpoints when enabled
+func (s *Service) createMessageParams(req *request, messages []anthropic.MessageParam) anthropic.MessageNewParams {
 	params := s.defaultParams
-	params.Messages = messages
+	params.System = []anthropic.TextBlockParam{{Text: req.system}}
+	params.Tools = s.toolRegistry.Tools(req.toolPolicy)
+	params.Messages = mergeTurns(messages)
+	if s.promptCaching {
+		addCacheBreakpoints(&params)
+	}
 	return params
 }
 
-func (s *Service) buildConversationMessages(messages []*discordgo.Message) ([]anthropic.MessageParam, string, error) {
-	if len(messages) == 0 {
-		return nil, "", fmt.Errorf("no valid messages found in conversation context")
-	}
-	channelID := messages[0].ChannelID
+// buildConversationMessages converts Discord messages, newest first, into conversation turns.
+// Only this bot's own messages become assistant turns; everyone else, including other bots,
+// is a labelled user turn, and consecutive turns from the same role are merged.
+func (s *Service) buildConversationMessages(ctx context.Context, messages []*discordgo.Message) ([]anthropic.MessageParam, error) {
+	botUserID := s.messenger.UserID()
 
 	var conversationMessages []anthropic.MessageParam
 	cleanedMessages := slices.DeleteFunc(messages, func(msg *discordgo.Message) bool {
-		return msg.Content == ""
+		return msg.Content == "" && len(msg.Attachments) == 0
 	})
 	for _, msg := range slices.Backward(cleanedMessages) {
-		var msgFunc = anthropic.NewUserMessage
-		if msg.Author.Bot {
-			msgFunc = anthropic.NewAssistantMessage
+		if msg.Author.ID == botUserID {
+			if msg.Content != "" {
+				conversationMessages = append(conversationMessages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(msg.Content)))
+			}
+			continue
 		}
-		messageParam := msgFunc(anthropic.NewTextBlock(msg.Content))
-		conversationMessages = append(conversationMessages, messageParam)
+		text := speakerLabel(msg) + "\n" + msg.Content
+		blocks := append([]anthropic.ContentBlockParamUnion{anthropic.NewTextBlock(text)}, s.attachmentBlocks(ctx, msg)...)
+		conversationMessages = append(conversationMessages, anthropic.NewUserMessage(blocks...))
 	}
 
 	if len(conversationMessages) == 0 {
-		return nil, "", fmt.Errorf("no valid messages found in conversation context")
+		return nil, fmt.Errorf("no valid messages found in conversation context")
+	}
+
+	return mergeTurns(conversationMessages), nil
+}
+
+// createMessage sends a request to Claude, streaming the text into reply when it is set.
+// When the request's model is overloaded or unavailable, its fallbacks are tried in order,
+// and the first that answers is kept for the rest of the request.
+func (s *Service) createMessage(ctx context.Context, req *request, params anthropic.MessageNewParams, reply *streamWriter) (*anthropic.Message, error) {
+	for {
+		attempt := params
+		attempt.Model = anthropic.Model(req.models[0])
+		// A fallback that can't think answers without thinking
+		if req.thinking > 0 && supportsThinking(req.models[0]) {
+			enableThinking(&attempt, req.thinking)
+		}
+
+		written := 0
+		if reply != nil {
+			written = reply.written
+		}
+		resp, err := s.sendWithRetry(ctx, attempt, reply, len(req.models) > 1)
+		if err == nil {
+			return resp, nil
+		}
+
+		// Falling back after text was streamed would repeat it
+		if len(req.models) == 1 || !shouldFallBack(err) || ctx.Err() != nil || (reply != nil && reply.written != written) {
+			return nil, err
+		}
+		s.logger.Warn("model unavailable, falling back", "model", req.models[0], "fallback", req.models[1], "error", err)
+		req.models = req.models[1:]
 	}
+}
 
-	return conversationMessages, channelID, nil
+// sendDiscordMessage posts a complete message to a channel, skipping empty content. The
+// messenger splits content too long for one message, such as a continued reply.
+func (s *Service) sendDiscordMessage(channelID, content string) {
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	if _, err := s.messenger.PostMessage(channelID, content); err != nil {
+		s.logger.Error("failed to send Discord message", "channelID", channelID, "error", err)
+	}
 }
 
-// GenerateResponse generates an AI response to a user message
-func (s *Service) GenerateResponse(ctx context.Context, messages []*discordgo.Message) (string, error) {
-	if len(messages) == 0 {
-		return "", fmt.Errorf("no messages provided")
+// GenerateResponse generates an AI response to a user message, continuing the stored
+// conversation for the message's channel or thread
+func (s *Service) GenerateResponse(ctx context.Context, message *discordgo.Message) (string, error) {
+	if message == nil {
+		return "", fmt.Errorf("no message provided")
 	}
+	channelID := message.ChannelID
+	req := &request{
+		guildID:    message.GuildID,
+		channelID:  channelID,
+		userID:     message.Author.ID,
+		models:     s.router.Route(message),
+		system:     s.systemPrompt(message),
+		toolPolicy: s.toolPolicies.For(message.GuildID, channelID),
+		reveal:     cmp.Or(s.router.For(message.GuildID, channelID).RevealThinking, s.revealThinking),
+	}
+	req.thinking = s.thinkingBudget(req.guildID, channelID, req.models[0])
+
+	// Serialize requests per channel so concurrent replies don't interleave the history
+	unlock := s.lockChannel(channelID)
+	defer unlock()
 
-	conversationMessages, channelID, err := s.buildConversationMessages(messages)
+	if exceeded := s.checkQuotas(req); exceeded != nil {
+		return quotaExplanation(exceeded), nil
+	}
+	if err := s.breaker.Check(); err != nil {
+		var openErr *CircuitOpenError
+		if !errors.As(err, &openErr) {
+			return "", err
+		}
+		// Don't touch the history while the API is down; the message is picked up next time
+		return unavailableExplanation(openErr.RetryAt), nil
+	}
+
+	conv, err := s.store.Load(channelID)
 	if err != nil {
 		return "", err
 	}
 
+	newMessages := s.unseenMessages(conv, message)
+	if len(newMessages) == 0 {
+		// An earlier request already answered this message along with its own
+		s.logger.Debug("message already part of the conversation", "messageID", message.ID)
+		return "", nil
+	}
+	lastMessageID := newMessages[0].ID
+
+	turns, err := s.buildConversationMessages(ctx, newMessages)
+	if err != nil {
+		return "", err
+	}
+
+	if s.budget.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.budget.Timeout)
+		defer cancel()
+	}
+	window := s.contextWindow(ctx, req, conv, estimateTokens(turns))
+	history := append(slices.Clip(window), turns...)
+
+	result, response, err := s.runConversation(ctx, req, history)
+
+	// Persist the new user turns, plus the full exchange when it completed cleanly
+	saved := turns
+	if result != nil {
+		saved = withoutThinking(result[len(window):])
+	}
+	s.saveConversation(channelID, saved, lastMessageID)
+
+	return response, err
+}
+
+// runConversation runs the tool loop until Claude produces a final answer, returning the
+// resulting history. A nil history means the exchange ended in an inconsistent state.
+func (s *Service) runConversation(ctx context.Context, req *request, conversationMessages []anthropic.MessageParam) ([]anthropic.MessageParam, string, error) {
+	// When streaming, all rounds of the tool loop render into the same reply
+	notes := &footnotes{}
+	var reply *streamWriter
+	if s.streaming {
+		reply = newStreamWriter(s.messenger, req.channelID, s.editInterval, notes, s.logger)
+		reply.reveal = req.reveal == revealSpoiler
+		defer reply.Close()
+	}
+	// Reasoning revealed in a follow-up message is collected across all rounds
+	var reasoning []string
+	// A reply cut off by the max tokens limit is continued, and its pieces are sent as one
+	continuations := 0
+	var partial string    // text of the pieces so far, when not streaming
+	var spoilers []string // reasoning to reveal before the stitched reply, when not streaming
+
+	budget := newBudgetTracker(s.budget)
+	budget.toolErrors = s.consecutiveToolErrors(req.channelID)
+	defer s.saveToolErrors(req.channelID, budget)
+	finalRound := false
+
 	for {
-		s.logger.Info("generating response")
-		resp, err := s.client.Messages.New(ctx, s.createMessageParams(conversationMessages))
-		if err != nil || len(resp.Content) == 0 {
-			return "", fmt.Errorf("failed to generate AI response: %w", err)
+		params := s.createMessageParams(req, conversationMessages)
+		if finalRound && len(params.Tools) > 0 {
+			// Out of tool rounds: make Claude answer with what it has
+			params.ToolChoice = anthropic.ToolChoiceUnionParam{OfNone: &anthropic.ToolChoiceNoneParam{}}
 		}
 
+		s.logger.Info("generating response", "model", req.models[0], "streaming", s.streaming, "thinking", req.thinking, "round", budget.rounds)
+		resp, err := s.createMessage(ctx, req, params, reply)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				s.logBudgetExhausted(budget, budgetDeadline)
+				return nil, budgetExplanation(budgetDeadline), nil
+			}
+			var openErr *CircuitOpenError
+			if errors.As(err, &openErr) {
+				return nil, unavailableExplanation(openErr.RetryAt), nil
+			}
+			return nil, "", fmt.Errorf("failed to generate AI response: %w", err)
+		}
+		if len(resp.Content) == 0 {
+			return nil, "", fmt.Errorf("failed to generate AI response: empty response")
+		}
+		budget.record(resp.Usage)
+		record := s.recordUsage(req, string(resp.Model), resp.Usage)
+		s.logger.Info("response usage",
+			"cost", record.Cost,
+			"inputTokens", resp.Usage.InputTokens,
+			"outputTokens", resp.Usage.OutputTokens,
+			"cacheReadTokens", resp.Usage.CacheReadInputTokens,
+			"cacheWriteTokens", resp.Usage.CacheCreationInputTokens,
+			"webSearches", resp.Usage.ServerToolUse.WebSearchRequests,
+		)
+
 		var toolUses []anthropic.ToolUseBlock
-		var textBlocks []string
 		for _, block := range resp.Content {
-			switch block.Type {
-			case "tool_use":
+			if block.Type == "tool_use" {
 				toolUses = append(toolUses, block.AsToolUse())
-			case "text":
-				textBlocks = append(textBlocks, block.AsText().Text)
 			}
 		}
 
-		s.sendDiscordMessage(channelID, strings.Join(textBlocks, "\n"))
+		truncated := resp.StopReason == "max_tokens"
+		continuing := truncated && continuations < s.maxContinuations && budget.exhausted() == ""
+
+		text := stitch(partial, resp, responseText(resp, notes))
+		partial = ""
+		if truncated && !continuing {
+			s.logger.Warn("response cut off at the max tokens limit", "continuations", continuations)
+			text = strings.TrimSpace(text + "\n\n" + truncatedNotice)
+			if reply != nil {
+				reply.Break()
+				reply.Write(truncatedNotice)
+			}
+		}
+
+		// Cited sources are listed once, after the final answer
+		paused := resp.StopReason == "tool_use" || resp.StopReason == "pause_turn" || continuing
+		if sources := notes.String(); sources != "" && !paused {
+			if reply != nil {
+				reply.Break()
+				reply.Write(sources)
+			} else {
+				text += "\n\n" + sources
+			}
+		}
+
+		if thinking := thinkingText(resp); thinking != "" {
+			switch {
+			case req.reveal == revealMessage:
+				reasoning = append(reasoning, thinking)
+			case req.reveal == revealSpoiler && reply == nil:
+				spoilers = append(spoilers, thinking)
+			}
+		}
+
+		if continuing {
+			continuations++
+			partial = text
+			s.logger.Info("response cut off, continuing", "continuation", continuations, "outputTokens", resp.Usage.OutputTokens)
+			conversationMessages = append(conversationMessages, continuation(resp, req.thinking > 0 && supportsThinking(req.models[0]))...)
+			continue
+		}
+
+		if reply != nil {
+			reply.Break()
+		} else if len(spoilers) > 0 {
+			s.sendDiscordMessage(req.channelID, strings.TrimSpace(thinkingSpoiler(strings.Join(spoilers, "\n\n"))+"\n\n"+text))
+			spoilers = nil
+		} else {
+			s.sendDiscordMessage(req.channelID, text)
+		}
+
+		// A long server tool turn can be paused; send it back as-is so Claude carries on
+		if resp.StopReason == "pause_turn" {
+			budget.rounds++
+			conversationMessages = append(conversationMessages, assistantMessage(resp))
+			if exhausted := budget.exhausted(); exhausted != "" {
+				s.logBudgetExhausted(budget, exhausted)
+				return nil, budgetExplanation(exhausted), nil
+			}
+			continue
+		}
 
 		// Check if the response stopped due to tool use
 		if resp.StopReason == "tool_use" {
+			budget.rounds++
+
 			// First, add the assistant message with tool uses
-			assistantBlocks := []anthropic.ContentBlockParamUnion{}
-			for _, block := range resp.Content {
-				assistantBlocks = append(assistantBlocks, block.ToParam())
+			conversationMessages = append(conversationMessages, assistantMessage(resp))
+
+			if exhausted := budget.exhausted(); exhausted != "" {
+				s.logBudgetExhausted(budget, exhausted)
+				if !budgetAllowsFinalAnswer(exhausted) || finalRound {
+					return nil, budgetExplanation(exhausted), nil
+				}
+
+				// Answer the pending tool calls without running them, then ask for a final answer
+				for _, toolUse := range toolUses {
+					toolResultMessage := anthropic.NewUserMessage(anthropic.NewToolResultBlock(toolUse.ID, budgetExhaustedNotice, true))
+					conversationMessages = append(conversationMessages, toolResultMessage)
+				}
+				finalRound = true
+				continue
 			}
-			assistantMessage := anthropic.NewAssistantMessage(assistantBlocks...)
-			conversationMessages = append(conversationMessages, assistantMessage)
-
-			// Then add tool results as user messages
-			for _, toolUse := range toolUses {
-				toolResultBlock, err := s.toolRegistry.ExecuteTool(toolUse.Name, toolUse.Input, toolUse.ID)
-				if err != nil {
-					return fmt.Sprintf("Sorry, I encountered an error while using a tool: %v", err), nil
+
+			// Then run the tools in parallel and add their results as user messages;
+			// failures go back to Claude as error results
+			toolResultBlocks, toolErrs := s.toolRegistry.ExecuteTools(ctx, toolUses, req.toolPolicy)
+			for i, toolResultBlock := range toolResultBlocks {
+				if toolErrs[i] != nil {
+					s.logger.Warn("tool call failed", "tool", toolUses[i].Name, "error", toolErrs[i])
+					budget.toolErrors++
+				} else {
+					budget.toolErrors = 0
 				}
 				toolResultMessage := anthropic.NewUserMessage(toolResultBlock)
 				conversationMessages = append(conversationMessages, toolResultMessage)
@@ -132,11 +525,54 @@ func (s *Service) GenerateResponse(ctx context.Context, messages []*discordgo.Me
 			continue
 		}
 
-		// If the response stopped for any other reason (end_turn, max_tokens, etc.), we've already sent the text
-		if len(textBlocks) > 0 {
-			return "", nil // Text already sent to Discord
+		// If the response stopped for any other reason (end_turn, or max_tokens out of continuations), we've already sent the text
+		if text != "" {
+			if !truncated {
+				conversationMessages = append(conversationMessages, assistantMessage(resp))
+			} else if blocks := partialReply(resp); len(blocks) > 0 {
+				conversationMessages = append(conversationMessages, anthropic.NewAssistantMessage(blocks...))
+			}
+			if len(reasoning) > 0 {
+				// The answer has to be in Discord before the reasoning follows it
+				if reply != nil {
+					reply.Close()
+				}
+				s.sendDiscordMessage(req.channelID, thinkingMessage(strings.Join(reasoning, "\n\n")))
+			}
+			return conversationMessages, "", nil // Text already sent to Discord
 		}
 
-		return "", fmt.Errorf("no text response in final message")
+		return nil, "", fmt.Errorf("no text response in final message")
 	}
 }
+
+// consecutiveToolErrors returns how many tool calls in a row have failed in a channel's
+// conversation, so a tool that keeps failing is given up on even when each request only
+// trips over it once or twice
+func (s *Service) consecutiveToolErrors(channelID string) int {
+	if n, ok := s.toolErrors.Load(channelID); ok {
+		return n.(int)
+	}
+	return 0
+}
+
+// saveToolErrors keeps a request's count of consecutive tool errors for the next request in
+// the channel. A count that reached the cap starts over, since the request that hit it has
+// already answered without tools.
+func (s *Service) saveToolErrors(channelID string, budget *budgetTracker) {
+	if budget.toolErrors == 0 || (s.budget.MaxToolErrors > 0 && budget.toolErrors >= s.budget.MaxToolErrors) {
+		s.toolErrors.Delete(channelID)
+		return
+	}
+	s.toolErrors.Store(channelID, budget.toolErrors)
+}
+
+// logBudgetExhausted logs which budget stopped a request along with the resources it used
+func (s *Service) logBudgetExhausted(budget *budgetTracker, exhausted string) {
+	s.logger.Warn("request budget exhausted",
+		"budget", exhausted,
+		"rounds", budget.rounds,
+		"inputTokens", budget.inputTokens,
+		"outputTokens", budget.outputTokens,
+	)
+}
@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/charmbracelet/log"
+
+	"discord-assist/internal/discord"
+)
+
+// streamPlaceholder is posted while waiting for the first text delta
+const streamPlaceholder = "✍️ *Thinking...*"
+
+// streamWriter progressively renders streamed text into Discord messages
+type streamWriter struct {
+	messenger Messenger
+	logger    *log.Logger
+	channelID string
+	interval  time.Duration
+	notes     *footnotes // numbers citations across the whole reply
+	reveal    bool       // show reasoning in a spoiler as each thinking block ends
+
+	messageID string    // message currently being edited
+	content   string    // content of the message currently being edited
+	written   int       // characters written across the whole reply
+	dirty     bool      // content has changed since the last edit
+	separate  bool      // next write should start a new paragraph
+	lastEdit  time.Time // time of the last edit, used for throttling
+}
+
+// newStreamWriter creates a stream writer and posts its placeholder message
+func newStreamWriter(messenger Messenger, channelID string, interval time.Duration, notes *footnotes, logger *log.Logger) *streamWriter {
+	w := &streamWriter{
+		messenger: messenger,
+		logger:    logger,
+		channelID: channelID,
+		interval:  interval,
+		notes:     notes,
+	}
+
+	msg, err := messenger.PostMessage(channelID, streamPlaceholder)
+	if err != nil {
+		logger.Warn("failed to post placeholder message", "channelID", channelID, "error", err)
+	} else {
+		w.messageID = msg.ID
+	}
+	w.lastEdit = time.Now()
+
+	return w
+}
+
+// Write appends a text delta and edits the Discord message if the throttle allows it
+func (w *streamWriter) Write(text string) {
+	if text == "" {
+		return
+	}
+	if w.separate && w.content != "" {
+		text = "\n\n" + text
+	}
+	w.separate = false
+	w.written += len(text)
+	w.content += text
+	w.dirty = true
+
+	// Roll over into a new message once the current one is full
+	for utf8.RuneCountInString(w.content) > discord.MaxMessageLength {
+		head, tail := discord.SplitFirst(w.content, discord.MaxMessageLength)
+		w.content = head
+		w.flush()
+		w.messageID = ""
+		w.content = tail
+		w.dirty = true
+	}
+
+	if time.Since(w.lastEdit) >= w.interval {
+		w.flush()
+	}
+}
+
+// Break makes the next write start on a new paragraph, used between tool rounds. Text held
+// back by the edit throttle is flushed, since nothing more may arrive while a tool runs.
+func (w *streamWriter) Break() {
+	w.separate = true
+	w.flush()
+}
+
+// Text implements StreamHandler by writing a text delta
+func (w *streamWriter) Text(delta string) {
+	w.Write(delta)
+}
+
+// TextDone implements StreamHandler by following the text with its citations' footnote markers
+func (w *streamWriter) TextDone(citations []anthropic.TextCitationUnion) {
+	w.Write(w.notes.markers(citations))
+}
+
+// ServerToolStart implements StreamHandler by starting a new paragraph after the tool runs
+func (w *streamWriter) ServerToolStart() {
+	w.Break()
+}
+
+// ThinkingDone implements StreamHandler by showing the reasoning in a spoiler, when revealed
+func (w *streamWriter) ThinkingDone(thinking string) {
+	if !w.reveal {
+		return
+	}
+	w.Write(thinkingSpoiler(thinking))
+	w.Break()
+}
+
+// Close flushes any pending text, removing the placeholder if nothing was written
+func (w *streamWriter) Close() {
+	if strings.TrimSpace(w.content) == "" {
+		if w.messageID != "" {
+			if err := w.messenger.DeleteMessage(w.channelID, w.messageID); err != nil {
+				w.logger.Warn("failed to delete placeholder message", "channelID", w.channelID, "error", err)
+			}
+			w.messageID = ""
+		}
+		return
+	}
+	w.flush()
+}
+
+// flush writes the current content to Discord, posting a new message if needed
+func (w *streamWriter) flush() {
+	if !w.dirty || strings.TrimSpace(w.content) == "" {
+		return
+	}
+	w.lastEdit = time.Now()
+	w.dirty = false
+
+	if w.messageID == "" {
+		msg, err := w.messenger.PostMessage(w.channelID, w.content)
+		if err != nil {
+			w.logger.Error("failed to post streamed message", "channelID", w.channelID, "error", err)
+			return
+		}
+		w.messageID = msg.ID
+		return
+	}
+
+	if err := w.messenger.EditMessage(w.channelID, w.messageID, w.content); err != nil {
+		w.logger.Error("failed to edit streamed message", "channelID", w.channelID, "error", err)
+	}
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/charmbracelet/log"
+)
+
+// recordingMessenger is a Messenger that keeps the latest content of each message
+type recordingMessenger struct {
+	Messenger
+	messages map[string]string
+	edits    int
+}
+
+func (m *recordingMessenger) PostMessage(_, content string) (*discordgo.Message, error) {
+	id := strconv.Itoa(len(m.messages) + 1)
+	m.messages[id] = content
+	return &discordgo.Message{ID: id}, nil
+}
+
+func (m *recordingMessenger) EditMessage(_, messageID, content string) error {
+	m.messages[messageID] = content
+	m.edits++
+	return nil
+}
+
+func (m *recordingMessenger) DeleteMessage(_, messageID string) error {
+	delete(m.messages, messageID)
+	return nil
+}
+
+func TestStreamWriterBreakFlushes(t *testing.T) {
+	messenger := &recordingMessenger{messages: map[string]string{}}
+	w := newStreamWriter(messenger, "channel", time.Hour, &footnotes{}, log.New(io.Discard))
+
+	w.Write("Let me look that up.")
+	if got := messenger.messages["1"]; got != streamPlaceholder {
+		t.Fatalf("message before the throttle allows an edit = %q, want the placeholder", got)
+	}
+
+	w.Break()
+	if got := messenger.messages["1"]; got != "Let me look that up." {
+		t.Errorf("message after Break = %q, want the buffered text", got)
+	}
+
+	w.Break()
+	if messenger.edits != 1 {
+		t.Errorf("Break with nothing new edited the message again (%d edits)", messenger.edits)
+	}
+
+	w.Write("Found it.")
+	w.Close()
+	if got := messenger.messages["1"]; got != "Let me look that up.\n\nFound it." {
+		t.Errorf("message after Close = %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
+)
+
+const (
+	// minThinkingBudget is the smallest thinking budget the API accepts
+	minThinkingBudget = 1024
+	// maxRevealLength caps how many characters of reasoning are shown in Discord
+	maxRevealLength = 1500
+
+	// revealSpoiler shows reasoning in a spoiler before the answer
+	revealSpoiler = "spoiler"
+	// revealMessage shows reasoning in a follow-up message after the answer
+	revealMessage = "message"
+)
+
+// noThinkingModels are the ID prefixes of models that predate extended thinking
+var noThinkingModels = []string{"claude-3-5-", "claude-3-haiku", "claude-3-opus", "claude-3-sonnet"}
+
+// supportsThinking reports whether a model can use extended thinking
+func supportsThinking(model string) bool {
+	for _, prefix := range noThinkingModels {
+		if strings.HasPrefix(model, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// thinkingBudget returns the extended thinking budget for a request's model, or 0 to
+// leave thinking off. A budget set for the persona wins over one set for the model, which
+// wins over the configured default.
+func (s *Service) thinkingBudget(guildID, channelID, model string) int64 {
+	// Only Anthropic's API thinks; other providers would just spend the extra max tokens
+	if _, ok := s.provider.(*AnthropicProvider); !ok || !supportsThinking(model) {
+		return 0
+	}
+	budget := s.thinkingTokens
+	if modelBudget, ok := s.router.ThinkingBudget(model); ok {
+		budget = modelBudget
+	}
+	if personaBudget, ok := s.personas.ThinkingBudget(guildID, channelID); ok {
+		budget = personaBudget
+	}
+	if budget <= 0 {
+		return 0
+	}
+	return int64(max(budget, minThinkingBudget))
+}
+
+// enableThinking turns on extended thinking for a request. The budget comes on top of the
+// answer's own max tokens, and thinking requires the default temperature.
+func enableThinking(params *anthropic.MessageNewParams, budget int64) {
+	params.Thinking = anthropic.ThinkingConfigParamOfEnabled(budget)
+	params.MaxTokens += budget
+	params.Temperature = param.Opt[float64]{}
+}
+
+// thinkingParam converts a thinking or redacted thinking block back into a request block.
+// Both must be sent back unchanged while a tool loop runs, or the API rejects the request.
+func thinkingParam(block anthropic.ContentBlockUnion) anthropic.ContentBlockParamUnion {
+	if block.Type == "redacted_thinking" {
+		return anthropic.ContentBlockParamUnion{OfRedactedThinking: &anthropic.RedactedThinkingBlockParam{Data: block.Data}}
+	}
+	return anthropic.ContentBlockParamUnion{OfThinking: &anthropic.ThinkingBlockParam{
+		Signature: block.Signature,
+		Thinking:  block.Thinking,
+	}}
+}
+
+// withoutThinking returns messages with their thinking blocks removed. The API ignores
+// thinking from earlier turns, so it isn't worth storing once the exchange is done.
+func withoutThinking(messages []anthropic.MessageParam) []anthropic.MessageParam {
+	stripped := make([]anthropic.MessageParam, 0, len(messages))
+	for _, message := range messages {
+		content := make([]anthropic.ContentBlockParamUnion, 0, len(message.Content))
+		for _, block := range message.Content {
+			if block.OfThinking == nil && block.OfRedactedThinking == nil {
+				content = append(content, block)
+			}
+		}
+		if len(content) == 0 {
+			continue
+		}
+		message.Content = content
+		stripped = append(stripped, message)
+	}
+	return stripped
+}
+
+// thinkingText returns the readable reasoning in a response; redacted thinking is skipped
+func thinkingText(resp *anthropic.Message) string {
+	var parts []string
+	for _, block := range resp.Content {
+		if block.Type == "thinking" && strings.TrimSpace(block.Thinking) != "" {
+			parts = append(parts, strings.TrimSpace(block.Thinking))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// thinkingSpoiler formats reasoning as a collapsed spoiler to show before an answer
+func thinkingSpoiler(thinking string) string {
+	thinking = strings.TrimSpace(thinking)
+	if thinking == "" {
+		return ""
+	}
+	// A "||" inside the reasoning would end the spoiler early
+	thinking = strings.ReplaceAll(truncateReveal(thinking), "||", "|\u200b|")
+	return fmt.Sprintf("-# 💭 Reasoning\n||%s||", thinking)
+}
+
+// thinkingMessage formats reasoning as a follow-up message to post after an answer
+func thinkingMessage(thinking string) string {
+	thinking = strings.TrimSpace(thinking)
+	if thinking == "" {
+		return ""
+	}
+	return "-# 💭 Reasoning\n>>> " + truncateReveal(thinking)
+}
+
+// truncateReveal shortens reasoning to maxRevealLength characters so it fits in one message
+func truncateReveal(thinking string) string {
+	if utf8.RuneCountInString(thinking) <= maxRevealLength {
+		return thinking
+	}
+	return strings.TrimSpace(string([]rune(thinking)[:maxRevealLength-1])) + "…"
+}
@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+// ToolPolicy is an allowlist/denylist of tool names. Entries may be path.Match patterns
+// such as "admin_*". Deny wins over Allow, and an empty Allow permits every tool.
+// Server tools are billed per use, so they are off unless listed in ServerTools.
+type ToolPolicy struct {
+	Allow       []string `json:"allow"`
+	Deny        []string `json:"deny"`
+	ServerTools []string `json:"serverTools"`
+}
+
+// Permits reports whether the policy lets the named tool be used. A nil policy permits everything.
+func (p *ToolPolicy) Permits(name string) bool {
+	if p == nil {
+		return true
+	}
+	if matchesAny(p.Deny, name) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchesAny(p.Allow, name)
+}
+
+// EnablesServerTool reports whether the policy turns on the named server tool. A nil policy enables none.
+func (p *ToolPolicy) EnablesServerTool(name string) bool {
+	if p == nil {
+		return false
+	}
+	return matchesAny(p.ServerTools, name) && !matchesAny(p.Deny, name)
+}
+
+// ToolPolicies holds the tool policies for each guild and channel.
+// The most specific policy wins: channel, then guild, then the default.
+type ToolPolicies struct {
+	Default  *ToolPolicy            `json:"default"`
+	Guilds   map[string]*ToolPolicy `json:"guilds"`
+	Channels map[string]*ToolPolicy `json:"channels"`
+}
+
+// LoadToolPolicies reads tool policies from a JSON file. An empty path permits every tool everywhere.
+func LoadToolPolicies(filename string) (*ToolPolicies, error) {
+	policies := &ToolPolicies{}
+	if filename == "" {
+		return policies, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tool policy file: %w", err)
+	}
+	if err := json.Unmarshal(data, policies); err != nil {
+		return nil, fmt.Errorf("failed to parse tool policy file: %w", err)
+	}
+
+	return policies, nil
+}
+
+// For returns the policy that applies to a message from the given guild and channel
+func (p *ToolPolicies) For(guildID, channelID string) *ToolPolicy {
+	if p == nil {
+		return nil
+	}
+	if policy, ok := p.Channels[channelID]; ok {
+		return policy
+	}
+	if policy, ok := p.Guilds[guildID]; ok && guildID != "" {
+		return policy
+	}
+	return p.Default
+}
+
+// matchesAny reports whether name matches any of the patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
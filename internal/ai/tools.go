@@ -1,152 +1,350 @@
 package ai
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 )
 
+// defaultToolTimeout bounds tools that don't set their own Timeout
+const defaultToolTimeout = 30 * time.Second
+
 // Tool represents a unified tool definition with both schema and execution logic
 type Tool struct {
 	anthropic.ToolParam
-	Execute func(params map[string]any) (string, error)
+	// Timeout bounds a single execution; zero uses the registry default
+	Timeout time.Duration
+	Execute func(ctx context.Context, params map[string]any) (string, error)
+
+	// schema validates input before Execute runs; tools built with NewTool always have one
+	schema *jsonSchema
 }
 
-// ToolRegistry holds all available tools
-type ToolRegistry struct {
-	tools map[string]*Tool
-}
-
-// Global tool registry instance
-var GlobalToolRegistry = &ToolRegistry{
-	tools: map[string]*Tool{
-		"get_current_time": {
-			ToolParam: anthropic.ToolParam{
-				Type:        anthropic.ToolTypeCustom,
-				Name:        "get_current_time",
-				Description: anthropic.String("Get the current time and date for a specified timezone"),
-				InputSchema: anthropic.ToolInputSchemaParam{
-					Type: "object",
-					Properties: map[string]any{
-						"timezone": map[string]any{
-							"type":        "string",
-							"description": "The timezone to get the time for (e.g., 'UTC', 'America/New_York')",
-							"default":     "UTC",
-						},
-					},
-					Required: []string{},
-				},
+// NewTool creates a tool whose input schema is generated from the struct type P.
+// Input is validated against the schema, defaults are applied, and the result is decoded
+// into P before execute runs. See schemaFor for the supported struct tags.
+func NewTool[P any](name, description string, execute func(ctx context.Context, params P) (string, error)) *Tool {
+	schema, err := schemaFor(reflect.TypeFor[P]())
+	if err != nil {
+		panic(fmt.Sprintf("ai: invalid input type for tool %s: %v", name, err))
+	}
+	if schema.Type != "object" {
+		panic(fmt.Sprintf("ai: input type for tool %s must be a struct", name))
+	}
+
+	return &Tool{
+		ToolParam: anthropic.ToolParam{
+			Type:        anthropic.ToolTypeCustom,
+			Name:        name,
+			Description: anthropic.String(description),
+			InputSchema: anthropic.ToolInputSchemaParam{
+				Type:       "object",
+				Properties: schema.Properties,
+				Required:   schema.Required,
 			},
-			Execute: func(params map[string]any) (string, error) {
-				timezone := "UTC"
-				if tz, ok := params["timezone"].(string); ok {
-					timezone = tz
-				}
+		},
+		schema: schema,
+		Execute: func(ctx context.Context, params map[string]any) (string, error) {
+			// Round-trip through JSON to decode the validated map into P
+			data, err := json.Marshal(params)
+			if err != nil {
+				return "", fmt.Errorf("failed to encode tool input: %w", err)
+			}
+			var typed P
+			if err := json.Unmarshal(data, &typed); err != nil {
+				return "", fmt.Errorf("failed to decode tool input: %w", err)
+			}
+			return execute(ctx, typed)
+		},
+	}
+}
+
+// ToolRegistry holds the tools available to a Service, both custom tools run by the bot and
+// server tools run by Anthropic. It is safe for concurrent use, so tools can be registered
+// and unregistered while requests are running.
+type ToolRegistry struct {
+	mu          sync.RWMutex
+	tools       map[string]*Tool
+	serverTools map[string]*ServerTool
+}
+
+// NewToolRegistry creates a registry containing the given tools
+func NewToolRegistry(tools ...*Tool) (*ToolRegistry, error) {
+	tr := &ToolRegistry{
+		tools:       make(map[string]*Tool, len(tools)),
+		serverTools: make(map[string]*ServerTool),
+	}
+	for _, tool := range tools {
+		if err := tr.Register(tool); err != nil {
+			return nil, err
+		}
+	}
+	return tr, nil
+}
+
+// Register adds a tool to the registry
+func (tr *ToolRegistry) Register(tool *Tool) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.registered(tool.Name) {
+		return fmt.Errorf("tool %s is already registered", tool.Name)
+	}
+	tr.tools[tool.Name] = tool
+	return nil
+}
+
+// RegisterServerTool adds a server tool to the registry
+func (tr *ToolRegistry) RegisterServerTool(tool *ServerTool) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.registered(tool.Name) {
+		return fmt.Errorf("tool %s is already registered", tool.Name)
+	}
+	tr.serverTools[tool.Name] = tool
+	return nil
+}
+
+// registered reports whether a custom or server tool has the given name. tr.mu must be held.
+func (tr *ToolRegistry) registered(name string) bool {
+	_, isTool := tr.tools[name]
+	_, isServerTool := tr.serverTools[name]
+	return isTool || isServerTool
+}
+
+// Unregister removes a custom or server tool from the registry and reports whether it was present
+func (tr *ToolRegistry) Unregister(name string) bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	exists := tr.registered(name)
+	delete(tr.tools, name)
+	delete(tr.serverTools, name)
+	return exists
+}
+
+// lookup returns the named tool if it is registered and permitted by the policy
+func (tr *ToolRegistry) lookup(name string, policy *ToolPolicy) (*Tool, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	tool, exists := tr.tools[name]
+	if !exists || !policy.Permits(name) {
+		return nil, false
+	}
+	return tool, true
+}
+
+// currentTimeParams is the input to get_current_time
+type currentTimeParams struct {
+	Timezone string `json:"timezone" description:"The timezone to get the time for (e.g., 'UTC', 'America/New_York')" default:"UTC"`
+}
+
+// weatherParams is the input to get_weather
+type weatherParams struct {
+	Location string `json:"location" description:"The city and state, or city and country" required:"true"`
+	Unit     string `json:"unit" description:"The temperature unit to use" enum:"celsius,fahrenheit" default:"celsius"`
+}
+
+// searchParams is the input to search_web
+type searchParams struct {
+	Query string `json:"query" description:"The search query" required:"true"`
+}
 
-				loc, err := time.LoadLocation(timezone)
+// fetchParams is the input to fetch_url
+type fetchParams struct {
+	URL string `json:"url" description:"The http or https URL of the page to read" required:"true"`
+}
+
+// builtinTools returns the tools every Service starts with
+func (s *Service) builtinTools() []*Tool {
+	tools := []*Tool{
+		NewTool("get_current_time", "Get the current time and date for a specified timezone",
+			func(ctx context.Context, params currentTimeParams) (string, error) {
+				loc, err := time.LoadLocation(params.Timezone)
 				if err != nil {
 					loc = time.UTC
 				}
 
 				now := time.Now().In(loc)
-				return fmt.Sprintf("Current time in %s: %s", timezone, now.Format("2006-01-02 15:04:05 MST")), nil
-			},
-		},
-		"get_weather": {
-			ToolParam: anthropic.ToolParam{
-				Type:        anthropic.ToolTypeCustom,
-				Name:        "get_weather",
-				Description: anthropic.String("Get current weather information for a specific location"),
-				InputSchema: anthropic.ToolInputSchemaParam{
-					Type: "object",
-					Properties: map[string]any{
-						"location": map[string]any{
-							"type":        "string",
-							"description": "The city and state, or city and country",
-						},
-						"unit": map[string]any{
-							"type":        "string",
-							"enum":        []string{"celsius", "fahrenheit"},
-							"description": "The temperature unit to use",
-							"default":     "celsius",
-						},
-					},
-					Required: []string{"location"},
-				},
+				return fmt.Sprintf("Current time in %s: %s", params.Timezone, now.Format("2006-01-02 15:04:05 MST")), nil
 			},
-			Execute: func(params map[string]any) (string, error) {
-				location, ok := params["location"].(string)
-				if !ok {
-					return "", fmt.Errorf("location parameter is required")
-				}
-
-				unit := "celsius"
-				if u, ok := params["unit"].(string); ok {
-					unit = u
+		),
+		NewTool("get_weather", "Get current weather information for a specific location",
+			func(ctx context.Context, params weatherParams) (string, error) {
+				weather, err := s.weather.CurrentWeather(ctx, params.Location, params.Unit)
+				if err != nil {
+					return "", err
 				}
-
-				// Mock weather data - in a real implementation, you'd call a weather API
-				return fmt.Sprintf("Weather in %s: 22°%s, Partly Cloudy, Humidity: 65%%", location, unit), nil
+				return weather.String(), nil
 			},
+		),
+	}
+
+	fetchURL := NewTool("fetch_url", "Fetch a web page and return its title and main text content, e.g. to read or summarize a link",
+		func(ctx context.Context, params fetchParams) (string, error) {
+			page, err := s.fetcher.Fetch(ctx, params.URL)
+			if err != nil {
+				return "", err
+			}
+			return formatPage(page, s.fetcher.config.MaxTokens), nil
 		},
-		"search_web": {
-			ToolParam: anthropic.ToolParam{
-				Type:        anthropic.ToolTypeCustom,
-				Name:        "search_web",
-				Description: anthropic.String("Search the web for current information on a specific topic"),
-				InputSchema: anthropic.ToolInputSchemaParam{
-					Type: "object",
-					Properties: map[string]any{
-						"query": map[string]any{
-							"type":        "string",
-							"description": "The search query",
-						},
-					},
-					Required: []string{"query"},
-				},
-			},
-			Execute: func(params map[string]any) (string, error) {
-				query, ok := params["query"].(string)
-				if !ok {
-					return "", fmt.Errorf("query parameter is required")
-				}
+	)
+	// Let the fetcher's own timeout fire first, with a clearer error
+	fetchURL.Timeout = max(defaultToolTimeout, s.fetcher.config.Timeout+5*time.Second)
+	tools = append(tools, fetchURL)
 
-				// Mock search results - in a real implementation, you'd call a search API
-				return fmt.Sprintf("Search results for '%s': Found 1,234 results. Here are the top 3:\n1. Example result 1\n2. Example result 2\n3. Example result 3", query), nil
+	// Without a search backend, offering search_web would only invite made-up results
+	if s.search != nil {
+		tools = append(tools, NewTool("search_web", "Search the web for current information on a specific topic",
+			func(ctx context.Context, params searchParams) (string, error) {
+				results, err := s.search.Search(ctx, params.Query)
+				if err != nil {
+					return "", err
+				}
+				return formatSearchResults(params.Query, results), nil
 			},
-		},
-	},
+		))
+	}
+
+	return tools
 }
 
-// ExecuteTool executes a tool by name with the given JSON input and returns a tool result block
-func (tr *ToolRegistry) ExecuteTool(name string, input json.RawMessage, toolUseID string) (anthropic.ContentBlockParamUnion, error) {
-	tool, exists := tr.tools[name]
+// ExecuteTools runs the tool calls from one assistant turn in parallel and returns their
+// result blocks in the same order. errs[i] is non-nil when call i failed; its block is then
+// an is_error result. Cancelling ctx stops any tools still running.
+func (tr *ToolRegistry) ExecuteTools(ctx context.Context, toolUses []anthropic.ToolUseBlock, policy *ToolPolicy) ([]anthropic.ContentBlockParamUnion, []error) {
+	blocks := make([]anthropic.ContentBlockParamUnion, len(toolUses))
+	errs := make([]error, len(toolUses))
+
+	var wg sync.WaitGroup
+	for i, toolUse := range toolUses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			blocks[i], errs[i] = tr.ExecuteTool(ctx, toolUse.Name, toolUse.Input, toolUse.ID, policy)
+		}()
+	}
+	wg.Wait()
+
+	return blocks, errs
+}
+
+// ExecuteTool executes a tool by name with the given JSON input and returns a tool result block.
+// Failures are returned as is_error results so Claude can see what went wrong and recover;
+// the error is also returned so callers can log and count it.
+func (tr *ToolRegistry) ExecuteTool(ctx context.Context, name string, input json.RawMessage, toolUseID string, policy *ToolPolicy) (anthropic.ContentBlockParamUnion, error) {
+	result, err := tr.executeTool(ctx, name, input, policy)
+	if err != nil {
+		return anthropic.NewToolResultBlock(toolUseID, fmt.Sprintf("Error: %v", err), true), err
+	}
+
+	return anthropic.NewToolResultBlock(toolUseID, result, false), nil
+}
+
+// executeTool looks up, validates and runs a tool within its timeout
+func (tr *ToolRegistry) executeTool(ctx context.Context, name string, input json.RawMessage, policy *ToolPolicy) (string, error) {
+	tool, exists := tr.lookup(name, policy)
 	if !exists {
-		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("unknown tool: %s", name)
+		return "", fmt.Errorf("unknown tool: %s", name)
 	}
 
+	var value any
+	if err := json.Unmarshal(input, &value); err != nil {
+		return "", fmt.Errorf("invalid tool input: %w", err)
+	}
 	var params map[string]any
-	if err := json.Unmarshal(input, &params); err != nil {
-		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("failed to parse tool input: %w", err)
+	switch value := value.(type) {
+	case map[string]any:
+		params = value
+	case string:
+		// Backends that can't parse a model's arguments hand them over as a string
+		return "", fmt.Errorf("invalid tool input: arguments must be a JSON object, got %q", value)
+	default:
+		return "", fmt.Errorf("invalid tool input: input must be an object, got %s", input)
+	}
+	if err := tool.validate(params); err != nil {
+		return "", fmt.Errorf("invalid tool input: %w", err)
 	}
 
-	result, err := tool.Execute(params)
-	if err != nil {
-		return anthropic.ContentBlockParamUnion{}, err
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = defaultToolTimeout
 	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	return anthropic.NewToolResultBlock(toolUseID, result, false), nil
+	// Run the tool in its own goroutine so a tool that ignores ctx can't hold up the request
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := tool.Execute(ctx, params)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case out := <-done:
+		if out.err != nil {
+			return "", fmt.Errorf("tool %s failed: %w", name, out.err)
+		}
+		return out.result, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("tool %s timed out after %s", name, timeout)
+		}
+		return "", fmt.Errorf("tool %s was cancelled: %w", name, ctx.Err())
+	}
+}
+
+// validate checks input against the tool's schema, or just the required parameters
+// for tools defined without one
+func (t *Tool) validate(params map[string]any) error {
+	if t.schema != nil {
+		return t.schema.validate(params, "")
+	}
+	for _, name := range t.InputSchema.Required {
+		value, ok := params[name]
+		if !ok {
+			return fmt.Errorf("missing required parameter %q", name)
+		}
+		if value == nil {
+			return fmt.Errorf("parameter %q is required", name)
+		}
+	}
+	return nil
 }
 
-// GetTools returns the available tools for the AI service
-func GetTools() []anthropic.ToolUnionParam {
+// Tools returns the custom tools permitted by the policy followed by the server tools it
+// enables, each sorted by name, for MessageNewParams
+func (tr *ToolRegistry) Tools(policy *ToolPolicy) []anthropic.ToolUnionParam {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	names := slices.Sorted(maps.Keys(tr.tools))
+	names = append(names, slices.Sorted(maps.Keys(tr.serverTools))...)
+
 	var unionTools []anthropic.ToolUnionParam
-	for _, tool := range GlobalToolRegistry.tools {
-		unionTools = append(unionTools, anthropic.ToolUnionParam{
-			OfTool: &tool.ToolParam,
-		})
+	for _, name := range names {
+		if tool, ok := tr.tools[name]; ok && policy.Permits(name) {
+			unionTools = append(unionTools, anthropic.ToolUnionParam{
+				OfTool: &tool.ToolParam,
+			})
+		}
+		if tool, ok := tr.serverTools[name]; ok && policy.EnablesServerTool(name) {
+			unionTools = append(unionTools, tool.Param)
+		}
 	}
 
 	return unionTools
@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/bwmarrin/discordgo"
+)
+
+// speakerLabel formats the attribution line prepended to a user turn so Claude can tell
+// speakers apart in a busy channel, e.g. "[Alice (<@123>) · 2025-01-02 15:04 UTC]"
+func speakerLabel(msg *discordgo.Message) string {
+	name := displayName(msg)
+	if msg.Author.Bot {
+		name += " [bot]"
+	}
+
+	return fmt.Sprintf("[%s (%s) · %s]", name, msg.Author.Mention(), msg.Timestamp.UTC().Format("2006-01-02 15:04 MST"))
+}
+
+// displayName returns the name the message's author goes by in the server
+func displayName(msg *discordgo.Message) string {
+	if msg.Member != nil && msg.Member.Nick != "" {
+		return msg.Member.Nick
+	}
+	return msg.Author.DisplayName()
+}
+
+// mergeTurns combines consecutive messages with the same role into a single turn.
+// The input is left untouched so stored histories are never modified in place.
+func mergeTurns(messages []anthropic.MessageParam) []anthropic.MessageParam {
+	merged := make([]anthropic.MessageParam, 0, len(messages))
+	for _, message := range messages {
+		if n := len(merged); n > 0 && merged[n-1].Role == message.Role {
+			content := make([]anthropic.ContentBlockParamUnion, 0, len(merged[n-1].Content)+len(message.Content))
+			content = append(content, merged[n-1].Content...)
+			content = append(content, message.Content...)
+			merged[n-1].Content = content
+			continue
+		}
+		merged = append(merged, message)
+	}
+	return merged
+}
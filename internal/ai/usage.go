@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"discord-assist/internal/usage"
+)
+
+// recordUsage estimates the cost of an API call and adds it to the usage ledger under the
+// request's guild, channel and user. Ledger failures are logged rather than failing the reply.
+func (s *Service) recordUsage(req *request, model string, u anthropic.Usage) usage.Record {
+	rec := s.pricing.NewRecord(model, u)
+	rec.Time = time.Now()
+	rec.GuildID = req.guildID
+	rec.ChannelID = req.channelID
+	rec.UserID = req.userID
+
+	if _, ok := s.pricing.Price(model); !ok {
+		if _, warned := s.unpricedModels.LoadOrStore(model, true); !warned {
+			s.logger.Warn("no price for model, recording its usage without cost", "model", model)
+		}
+	}
+	if s.ledger != nil {
+		if err := s.ledger.Record(rec); err != nil {
+			s.logger.Error("failed to record usage", "error", err)
+		}
+	}
+	return rec
+}
+
+// checkQuotas returns the quota the request's guild or user has used up, if any. A quota
+// that can't be checked lets the request through.
+func (s *Service) checkQuotas(req *request) *usage.QuotaExceeded {
+	if s.ledger == nil {
+		return nil
+	}
+	exceeded, err := s.quotas.Check(s.ledger, req.guildID, req.userID, time.Now())
+	if err != nil {
+		s.logger.Error("failed to check usage quotas", "error", err)
+		return nil
+	}
+	if exceeded != nil {
+		s.logger.Warn("usage quota exceeded",
+			"scope", exceeded.Scope,
+			"period", exceeded.Period,
+			"limit", exceeded.Limit,
+			"used", exceeded.Used,
+			"guildID", req.guildID,
+			"userID", req.userID,
+		)
+	}
+	return exceeded
+}
+
+// quotaExplanation tells the user which quota they've hit and when it resets, using a
+// Discord relative timestamp
+func quotaExplanation(exceeded *usage.QuotaExceeded) string {
+	who := "you've reached your"
+	if exceeded.Scope == usage.ScopeGuild {
+		who = "this server has reached its"
+	}
+	period := "daily"
+	if exceeded.Period == usage.PeriodMonth {
+		period = "monthly"
+	}
+	return fmt.Sprintf("Sorry, %s %s usage limit for me. ⏳ It resets <t:%d:R>!",
+		who, period, exceeded.ResetAt.Unix())
+}
@@ -0,0 +1,314 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WeatherProvider looks up current weather conditions for a location
+type WeatherProvider interface {
+	CurrentWeather(ctx context.Context, location, unit string) (*Weather, error)
+}
+
+// Weather is a snapshot of current conditions at a location
+type Weather struct {
+	Location    string // resolved place name, e.g. "Paris, Île-de-France, France"
+	Temperature float64
+	FeelsLike   float64
+	Unit        string // "celsius" or "fahrenheit"
+	Humidity    int    // relative humidity in percent
+	WindSpeed   float64
+	WindUnit    string // "km/h" or "mph"
+	Condition   string
+	ObservedAt  string // local time of the observation
+}
+
+// String formats the weather for a tool result
+func (w *Weather) String() string {
+	symbol := "C"
+	if w.Unit == "fahrenheit" {
+		symbol = "F"
+	}
+	return fmt.Sprintf("Weather in %s: %.1f°%s (feels like %.1f°%s), %s, humidity %d%%, wind %.1f %s (observed %s local time)",
+		w.Location, w.Temperature, symbol, w.FeelsLike, symbol, w.Condition, w.Humidity, w.WindSpeed, w.WindUnit, w.ObservedAt)
+}
+
+// LocationNotFoundError is returned when a location string can't be geocoded. Candidates
+// lists places with the right name whose region or country didn't match, if any.
+type LocationNotFoundError struct {
+	Location   string
+	Candidates []string
+}
+
+// Error implements the error interface
+func (e *LocationNotFoundError) Error() string {
+	if len(e.Candidates) > 0 {
+		return fmt.Sprintf("no location found matching %q; did you mean one of: %s? Retry with the full region or country name",
+			e.Location, strings.Join(e.Candidates, "; "))
+	}
+	return fmt.Sprintf("no location found matching %q; try a city name, optionally followed by region or country (e.g. 'Springfield, Illinois')", e.Location)
+}
+
+// OpenMeteoProvider is a WeatherProvider backed by the Open-Meteo geocoding and forecast APIs.
+// Results are cached per location, in metric units, and converted on the way out.
+type OpenMeteoProvider struct {
+	geocodingURL string
+	forecastURL  string
+	client       *http.Client
+	cacheTTL     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedWeather
+}
+
+// cachedWeather is a metric weather snapshot and when it stops being fresh
+type cachedWeather struct {
+	weather Weather
+	expires time.Time
+}
+
+// NewOpenMeteoProvider creates a provider using the given API base URLs, e.g.
+// "https://geocoding-api.open-meteo.com" and "https://api.open-meteo.com"
+func NewOpenMeteoProvider(geocodingURL, forecastURL string, cacheTTL time.Duration) *OpenMeteoProvider {
+	return &OpenMeteoProvider{
+		geocodingURL: strings.TrimRight(geocodingURL, "/"),
+		forecastURL:  strings.TrimRight(forecastURL, "/"),
+		client:       &http.Client{Timeout: 10 * time.Second},
+		cacheTTL:     cacheTTL,
+		cache:        make(map[string]cachedWeather),
+	}
+}
+
+// CurrentWeather returns current conditions for location in the given unit ("celsius" or "fahrenheit")
+func (p *OpenMeteoProvider) CurrentWeather(ctx context.Context, location, unit string) (*Weather, error) {
+	key := strings.ToLower(strings.TrimSpace(location))
+
+	weather, ok := p.cached(key)
+	if !ok {
+		fetched, err := p.fetch(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		weather = *fetched
+		p.store(key, weather)
+	}
+
+	return convertWeather(weather, unit), nil
+}
+
+// cached returns a fresh cached snapshot for key
+func (p *OpenMeteoProvider) cached(key string) (Weather, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return Weather{}, false
+	}
+	return entry.weather, true
+}
+
+// store caches a snapshot for key, dropping expired entries
+func (p *OpenMeteoProvider) store(key string, weather Weather) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range p.cache {
+		if now.After(entry.expires) {
+			delete(p.cache, k)
+		}
+	}
+	p.cache[key] = cachedWeather{weather: weather, expires: now.Add(p.cacheTTL)}
+}
+
+// geocodeResult is a single match from the Open-Meteo geocoding API
+type geocodeResult struct {
+	Name        string  `json:"name"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Country     string  `json:"country"`
+	CountryCode string  `json:"country_code"`
+	Admin1      string  `json:"admin1"`
+}
+
+// displayName formats the place as "Name, Region, Country"
+func (g geocodeResult) displayName() string {
+	parts := []string{g.Name}
+	for _, part := range []string{g.Admin1, g.Country} {
+		if part != "" && part != g.Name {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fetch geocodes the location and fetches its current weather in metric units
+func (p *OpenMeteoProvider) fetch(ctx context.Context, location string) (*Weather, error) {
+	place, err := p.geocode(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"latitude":  {strconv.FormatFloat(place.Latitude, 'f', 4, 64)},
+		"longitude": {strconv.FormatFloat(place.Longitude, 'f', 4, 64)},
+		"current":   {"temperature_2m,relative_humidity_2m,apparent_temperature,weather_code,wind_speed_10m"},
+		"timezone":  {"auto"},
+	}
+
+	var forecast struct {
+		Current struct {
+			Time                string  `json:"time"`
+			Temperature         float64 `json:"temperature_2m"`
+			RelativeHumidity    float64 `json:"relative_humidity_2m"`
+			ApparentTemperature float64 `json:"apparent_temperature"`
+			WeatherCode         int     `json:"weather_code"`
+			WindSpeed           float64 `json:"wind_speed_10m"`
+		} `json:"current"`
+	}
+	if err := p.getJSON(ctx, p.forecastURL+"/v1/forecast?"+query.Encode(), &forecast); err != nil {
+		return nil, fmt.Errorf("failed to fetch forecast: %w", err)
+	}
+
+	current := forecast.Current
+	return &Weather{
+		Location:    place.displayName(),
+		Temperature: current.Temperature,
+		FeelsLike:   current.ApparentTemperature,
+		Unit:        "celsius",
+		Humidity:    int(current.RelativeHumidity),
+		WindSpeed:   current.WindSpeed,
+		WindUnit:    "km/h",
+		Condition:   weatherCondition(current.WeatherCode),
+		ObservedAt:  strings.Replace(current.Time, "T", " ", 1),
+	}, nil
+}
+
+// geocode resolves a location string such as "Springfield, Illinois" to a place. The part
+// before the first comma is searched for; the rest picks between places with that name,
+// and if none match the places found are returned as candidates instead of guessing.
+func (p *OpenMeteoProvider) geocode(ctx context.Context, location string) (*geocodeResult, error) {
+	name, qualifier, _ := strings.Cut(location, ",")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &LocationNotFoundError{Location: location}
+	}
+
+	query := url.Values{
+		"name":     {name},
+		"count":    {"10"},
+		"language": {"en"},
+		"format":   {"json"},
+	}
+
+	var response struct {
+		Results []geocodeResult `json:"results"`
+	}
+	if err := p.getJSON(ctx, p.geocodingURL+"/v1/search?"+query.Encode(), &response); err != nil {
+		return nil, fmt.Errorf("failed to geocode location: %w", err)
+	}
+	if len(response.Results) == 0 {
+		return nil, &LocationNotFoundError{Location: location}
+	}
+
+	qualified := false
+	for _, part := range strings.Split(qualifier, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		qualified = true
+		for _, result := range response.Results {
+			for _, field := range []string{result.Admin1, result.Country, result.CountryCode} {
+				if strings.EqualFold(part, field) {
+					return &result, nil
+				}
+			}
+		}
+	}
+	if !qualified {
+		return &response.Results[0], nil
+	}
+
+	candidates := make([]string, len(response.Results))
+	for i, result := range response.Results {
+		candidates[i] = result.displayName()
+	}
+	return nil, &LocationNotFoundError{Location: location, Candidates: candidates}
+}
+
+// getJSON fetches a URL and decodes its JSON body into v
+func (p *OpenMeteoProvider) getJSON(ctx context.Context, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("weather API returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// convertWeather converts a metric snapshot to the requested unit
+func convertWeather(weather Weather, unit string) *Weather {
+	if unit == "fahrenheit" {
+		weather.Temperature = weather.Temperature*9/5 + 32
+		weather.FeelsLike = weather.FeelsLike*9/5 + 32
+		weather.Unit = "fahrenheit"
+		weather.WindSpeed = weather.WindSpeed / 1.609344
+		weather.WindUnit = "mph"
+	}
+	return &weather
+}
+
+// weatherCondition describes a WMO weather interpretation code
+func weatherCondition(code int) string {
+	switch code {
+	case 0:
+		return "Clear sky"
+	case 1:
+		return "Mainly clear"
+	case 2:
+		return "Partly cloudy"
+	case 3:
+		return "Overcast"
+	case 45, 48:
+		return "Fog"
+	case 51, 53, 55:
+		return "Drizzle"
+	case 56, 57:
+		return "Freezing drizzle"
+	case 61, 63, 65:
+		return "Rain"
+	case 66, 67:
+		return "Freezing rain"
+	case 71, 73, 75:
+		return "Snow"
+	case 77:
+		return "Snow grains"
+	case 80, 81, 82:
+		return "Rain showers"
+	case 85, 86:
+		return "Snow showers"
+	case 95:
+		return "Thunderstorm"
+	case 96, 99:
+		return "Thunderstorm with hail"
+	}
+	return "Unknown conditions"
+}
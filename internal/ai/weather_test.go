@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newOpenMeteoStub serves canned geocoding results for "Paris" and a fixed forecast,
+// counting forecast requests
+func newOpenMeteoStub(t *testing.T, forecasts *atomic.Int32) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") != "Paris" {
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		fmt.Fprint(w, `{"results":[
+			{"name":"Paris","latitude":48.8534,"longitude":2.3488,"country":"France","country_code":"FR","admin1":"Île-de-France"},
+			{"name":"Paris","latitude":33.6609,"longitude":-95.5555,"country":"United States","country_code":"US","admin1":"Texas"}
+		]}`)
+	})
+	mux.HandleFunc("/v1/forecast", func(w http.ResponseWriter, r *http.Request) {
+		forecasts.Add(1)
+		if r.URL.Query().Get("latitude") == "" {
+			http.Error(w, "missing latitude", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"current":{"time":"2026-01-02T15:00","temperature_2m":20,"relative_humidity_2m":55,
+			"apparent_temperature":18,"weather_code":2,"wind_speed_10m":16.09344}}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenMeteoProviderCurrentWeather(t *testing.T) {
+	var forecasts atomic.Int32
+	server := newOpenMeteoStub(t, &forecasts)
+	provider := NewOpenMeteoProvider(server.URL, server.URL, time.Minute)
+
+	tests := []struct {
+		name         string
+		location     string
+		unit         string
+		wantLocation string
+		wantTemp     float64
+		wantWind     float64
+	}{
+		{name: "first match", location: "Paris", unit: "celsius", wantLocation: "Paris, Île-de-France, France", wantTemp: 20, wantWind: 16.09344},
+		{name: "region qualifier", location: "Paris, Texas", unit: "celsius", wantLocation: "Paris, Texas, United States", wantTemp: 20, wantWind: 16.09344},
+		{name: "country code qualifier", location: "Paris, us", unit: "fahrenheit", wantLocation: "Paris, Texas, United States", wantTemp: 68, wantWind: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weather, err := provider.CurrentWeather(context.Background(), tt.location, tt.unit)
+			if err != nil {
+				t.Fatalf("CurrentWeather: %v", err)
+			}
+			if weather.Location != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", weather.Location, tt.wantLocation)
+			}
+			if diff := weather.Temperature - tt.wantTemp; diff > 0.01 || diff < -0.01 {
+				t.Errorf("Temperature = %.2f, want %.2f", weather.Temperature, tt.wantTemp)
+			}
+			if diff := weather.WindSpeed - tt.wantWind; diff > 0.01 || diff < -0.01 {
+				t.Errorf("WindSpeed = %.2f, want %.2f", weather.WindSpeed, tt.wantWind)
+			}
+			if weather.Condition != "Partly cloudy" || weather.ObservedAt != "2026-01-02 15:00" {
+				t.Errorf("got condition %q observed %q", weather.Condition, weather.ObservedAt)
+			}
+		})
+	}
+
+	// The unit is converted from the cached snapshot rather than fetched again
+	before := forecasts.Load()
+	if _, err := provider.CurrentWeather(context.Background(), "paris", "fahrenheit"); err != nil {
+		t.Fatalf("CurrentWeather: %v", err)
+	}
+	if forecasts.Load() != before {
+		t.Error("cached location fetched the forecast again")
+	}
+}
+
+func TestOpenMeteoProviderLocationNotFound(t *testing.T) {
+	var forecasts atomic.Int32
+	server := newOpenMeteoStub(t, &forecasts)
+	provider := NewOpenMeteoProvider(server.URL, server.URL, time.Minute)
+
+	tests := []struct {
+		name           string
+		location       string
+		wantCandidates bool
+	}{
+		{name: "unknown place", location: "Atlantis"},
+		{name: "empty name", location: ", France"},
+		{name: "unmatched qualifier", location: "Paris, TX", wantCandidates: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.CurrentWeather(context.Background(), tt.location, "celsius")
+			var notFound *LocationNotFoundError
+			if !errors.As(err, &notFound) {
+				t.Fatalf("CurrentWeather(%q) error = %v, want *LocationNotFoundError", tt.location, err)
+			}
+			if got := len(notFound.Candidates) > 0; got != tt.wantCandidates {
+				t.Errorf("candidates = %v, want some: %v", notFound.Candidates, tt.wantCandidates)
+			}
+			if tt.wantCandidates && !strings.Contains(err.Error(), "Paris, Texas, United States") {
+				t.Errorf("error %q doesn't list the Texas candidate", err)
+			}
+		})
+	}
+	if forecasts.Load() != 0 {
+		t.Error("fetched a forecast for a location that wasn't found")
+	}
+}
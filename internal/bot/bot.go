@@ -2,6 +2,7 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -12,7 +13,10 @@ import (
 
 	"discord-assist/internal/ai"
 	"discord-assist/internal/config"
+	"discord-assist/internal/conversation"
 	"discord-assist/internal/discord"
+	"discord-assist/internal/mcp"
+	"discord-assist/internal/usage"
 )
 
 // Bot represents the main bot instance
@@ -20,8 +24,15 @@ type Bot struct {
 	config  *config.Config
 	client  *discord.Client
 	ai      *ai.Service
+	mcp     *mcp.Manager
+	store   *conversation.BoltStore
+	ledger  *usage.BoltLedger
 	logger  *log.Logger
 	running bool
+
+	// ctx is cancelled on Stop so in-flight requests and tools are abandoned
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // New creates a new bot instance
@@ -33,33 +44,54 @@ func New(cfg *config.Config) (*Bot, error) {
 	})
 
 	// Create Discord client
-	client, err := discord.NewClient(cfg.Discord.Token, logger)
+	client, err := discord.NewClient(cfg.Discord.Token, cfg.Discord.AttachmentThreshold, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Discord client: %w", err)
 	}
 
-	// Create AI service
-	sendDiscordMessage := func(channelID, content string) {
-		_, err := client.Session().ChannelMessageSend(channelID, content)
-		if err != nil {
-			logger.Error("failed to send Discord message", "channelID", channelID, "error", err)
-		}
+	// Open conversation store
+	store, err := conversation.NewBoltStore(cfg.Conversation.StorePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open conversation store: %w", err)
+	}
+
+	// Open usage ledger
+	ledger, err := usage.NewBoltLedger(cfg.Usage.StorePath)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
 	}
 
-	aiService, err := ai.NewService(cfg.Anthropic.APIKey, cfg.Anthropic.Model, logger, sendDiscordMessage)
+	// Create AI service
+	aiService, err := ai.NewService(cfg, logger, client, store, ledger)
 	if err != nil {
+		store.Close()
+		ledger.Close()
 		return nil, fmt.Errorf("failed to create AI service: %w", err)
 	}
 
+	// Connect MCP servers' tools to the AI service
+	mcpServers, err := mcp.LoadConfig(cfg.MCP.ServersFile)
+	if err != nil {
+		store.Close()
+		ledger.Close()
+		return nil, fmt.Errorf("failed to load MCP servers: %w", err)
+	}
+	mcpManager := mcp.NewManager(mcpServers, aiService.ToolRegistry(), logger)
+
 	bot := &Bot{
 		config: cfg,
 		client: client,
 		ai:     aiService,
+		mcp:    mcpManager,
+		store:  store,
+		ledger: ledger,
 		logger: logger,
 	}
 
 	// Set up event handlers
 	bot.setupEventHandlers()
+	aiService.Breaker().OnChange(bot.handleBreakerChange)
 
 	return bot, nil
 }
@@ -68,13 +100,18 @@ func New(cfg *config.Config) (*Bot, error) {
 func (b *Bot) Start(ctx context.Context) error {
 	b.logger.Info("starting Discord bot...")
 	b.running = true
+	b.ctx, b.cancel = context.WithCancel(ctx)
 
 	// Connect to Discord
 	if err := b.client.Connect(ctx); err != nil {
 		b.running = false
+		b.cancel()
 		return fmt.Errorf("failed to connect to Discord: %w", err)
 	}
 
+	// Start MCP servers in the background; their tools appear once they connect
+	b.mcp.Start(b.ctx)
+
 	// Set bot activity
 	if err := b.client.SetActivity(b.config.Bot.ActivityType, b.config.Bot.Activity); err != nil {
 		b.logger.Warn("failed to set bot activity", "error", err)
@@ -93,10 +130,14 @@ func (b *Bot) Start(ctx context.Context) error {
 	return b.Stop()
 }
 
-// Stop stops the bot gracefully
+// Stop stops the bot gracefully. The bot can be started again afterwards.
 func (b *Bot) Stop() error {
 	b.logger.Info("stopping bot...")
 	b.running = false
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.mcp.Close()
 
 	if err := b.client.Close(); err != nil {
 		b.logger.Error("error closing Discord connection", "error", err)
@@ -107,6 +148,29 @@ func (b *Bot) Stop() error {
 	return nil
 }
 
+// Close stops the bot if it's running and releases the conversation store and usage
+// ledger files. The bot can't be started again afterwards.
+func (b *Bot) Close() error {
+	var errs []error
+	if b.running {
+		errs = append(errs, b.Stop())
+	}
+	if err := b.store.Close(); err != nil {
+		b.logger.Error("error closing conversation store", "error", err)
+		errs = append(errs, err)
+	}
+	if err := b.ledger.Close(); err != nil {
+		b.logger.Error("error closing usage ledger", "error", err)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// APIStatus describes whether the AI provider is reachable, for the menu bar
+func (b *Bot) APIStatus() string {
+	return b.ai.Breaker().Status().String()
+}
+
 // IsRunning returns whether the bot is currently running
 func (b *Bot) IsRunning() bool {
 	return b.running
@@ -138,16 +202,8 @@ func (b *Bot) handleMessageCreate(s *discordgo.Session, m *discordgo.MessageCrea
 		"channel", m.ChannelID,
 	)
 
-	// Get the last 5 messages from the channel
-	recentMessages, err := b.client.GetRecentMessages(m.ChannelID, 5)
-	if err != nil {
-		b.logger.Error("failed to fetch recent messages", "error", err)
-		// Fallback to just the current message
-		recentMessages = []*discordgo.Message{m.Message}
-	}
-
-	// Generate AI response with conversation context
-	response, err := b.ai.GenerateResponse(context.Background(), recentMessages)
+	// Generate AI response; earlier context comes from the conversation store
+	response, err := b.ai.GenerateResponse(b.ctx, m.Message)
 	if err != nil {
 		b.logger.Error("failed to generate AI response", "error", err)
 		response = "I'm sorry, I'm having trouble processing your message right now. 😅"
@@ -160,3 +216,19 @@ func (b *Bot) handleMessageCreate(s *discordgo.Session, m *discordgo.MessageCrea
 		}
 	}
 }
+
+// handleBreakerChange shows in the bot's Discord status when the AI provider is unavailable
+func (b *Bot) handleBreakerChange(status ai.BreakerStatus) {
+	switch status.State {
+	case ai.BreakerOpen:
+		b.logger.Error("AI provider unavailable, pausing requests", "failures", status.Failures, "retryAt", status.RetryAt, "error", status.LastErr)
+		if err := b.client.SetNotice("⚠️ AI unavailable, retrying soon"); err != nil {
+			b.logger.Warn("failed to set status notice", "error", err)
+		}
+	case ai.BreakerClosed:
+		b.logger.Info("AI provider available again")
+		if err := b.client.SetActivity(b.config.Bot.ActivityType, b.config.Bot.Activity); err != nil {
+			b.logger.Warn("failed to restore activity", "error", err)
+		}
+	}
+}
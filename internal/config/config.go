@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -12,16 +13,106 @@ import (
 // Config holds all configuration for the bot
 type Config struct {
 	Discord struct {
-		Token string
+		Token               string
+		AttachmentThreshold int
 	}
 	Bot struct {
 		Prefix       string
 		Activity     string
 		ActivityType string
 	}
+	LLM struct {
+		Provider string
+	}
+	OpenAI struct {
+		BaseURL string
+		APIKey  string
+		Model   string
+	}
 	Anthropic struct {
-		APIKey string
-		Model  string
+		APIKey           string
+		Model            string
+		PromptCaching    bool
+		MaxTokens        int
+		MaxContinuations int
+	}
+	Models struct {
+		FastModel    string
+		Fallbacks    []string
+		ChatMaxChars int
+		RoutesFile   string
+	}
+	Retry struct {
+		MaxRetries       int
+		BaseDelay        time.Duration
+		MaxDelay         time.Duration
+		BreakerThreshold int
+		BreakerCooldown  time.Duration
+	}
+	Personas struct {
+		Dir            string
+		ReloadInterval time.Duration
+	}
+	Thinking struct {
+		BudgetTokens int
+		Reveal       string
+	}
+	Streaming struct {
+		Enabled      bool
+		EditInterval time.Duration
+	}
+	Conversation struct {
+		StorePath string
+	}
+	Context struct {
+		MaxTokens    int
+		SummaryModel string
+	}
+	Attachments struct {
+		MaxImages     int
+		MaxImageBytes int
+		MaxTextBytes  int
+		MaxPDFBytes   int
+	}
+	Tools struct {
+		PolicyFile string
+	}
+	ServerTools struct {
+		WebSearchMaxUses int
+	}
+	MCP struct {
+		ServersFile string
+	}
+	Weather struct {
+		GeocodingURL string
+		ForecastURL  string
+		CacheTTL     time.Duration
+	}
+	Search struct {
+		URL        string
+		MaxResults int
+	}
+	Fetch struct {
+		AllowedHosts []string
+		DeniedHosts  []string
+		MaxBytes     int
+		Timeout      time.Duration
+		MaxTokens    int
+	}
+	Usage struct {
+		StorePath         string
+		PricingFile       string
+		GuildDailyLimit   float64
+		GuildMonthlyLimit float64
+		UserDailyLimit    float64
+		UserMonthlyLimit  float64
+	}
+	Budget struct {
+		MaxToolRounds   int
+		MaxToolErrors   int
+		MaxInputTokens  int
+		MaxOutputTokens int
+		Timeout         time.Duration
 	}
 	Server struct {
 		Port string
@@ -47,18 +138,123 @@ func Load() (*Config, error) {
 	if config.Discord.Token == "" {
 		return nil, fmt.Errorf("DISCORD_TOKEN is required")
 	}
+	config.Discord.AttachmentThreshold = getEnvInt("DISCORD_ATTACHMENT_THRESHOLD", 0)
 
 	// Bot configuration
 	config.Bot.Prefix = getEnv("BOT_PREFIX", "!")
 	config.Bot.Activity = getEnv("BOT_ACTIVITY", "with Discord")
 	config.Bot.ActivityType = getEnv("BOT_ACTIVITY_TYPE", "Playing")
 
+	// LLM provider configuration
+	config.LLM.Provider = getEnv("LLM_PROVIDER", "anthropic")
+	switch config.LLM.Provider {
+	case "anthropic", "openai":
+	default:
+		return nil, fmt.Errorf("LLM_PROVIDER must be anthropic or openai, got %q", config.LLM.Provider)
+	}
+
+	// OpenAI-compatible API configuration, used when LLM_PROVIDER is openai
+	config.OpenAI.BaseURL = getEnv("OPENAI_BASE_URL", "http://localhost:11434/v1")
+	config.OpenAI.APIKey = getEnv("OPENAI_API_KEY", "")
+	config.OpenAI.Model = getEnv("OPENAI_MODEL", "")
+	if config.OpenAI.Model == "" && config.LLM.Provider == "openai" {
+		// The routed model names are Claude IDs, which an OpenAI-compatible server won't know
+		return nil, fmt.Errorf("OPENAI_MODEL is required when LLM_PROVIDER is openai")
+	}
+
 	// Anthropic configuration
 	config.Anthropic.APIKey = getEnv("ANTHROPIC_API_KEY", "")
-	if config.Anthropic.APIKey == "" {
+	if config.Anthropic.APIKey == "" && config.LLM.Provider == "anthropic" {
 		return nil, fmt.Errorf("ANTHROPIC_API_KEY is required")
 	}
-	config.Anthropic.Model = getEnv("ANTHROPIC_MODEL", "claude-3-5-sonnet")
+	config.Anthropic.Model = getEnv("ANTHROPIC_MODEL", "claude-sonnet-4-0")
+	config.Anthropic.PromptCaching = getEnvBool("ANTHROPIC_PROMPT_CACHING", true)
+	config.Anthropic.MaxTokens = getEnvInt("ANTHROPIC_MAX_TOKENS", 1000)
+	config.Anthropic.MaxContinuations = getEnvInt("ANTHROPIC_MAX_CONTINUATIONS", 2)
+
+	// Model routing configuration
+	config.Models.FastModel = getEnv("ANTHROPIC_FAST_MODEL", "claude-haiku-4-5")
+	config.Models.Fallbacks = getEnvList("ANTHROPIC_FALLBACK_MODELS")
+	if len(config.Models.Fallbacks) == 0 {
+		config.Models.Fallbacks = []string{"claude-sonnet-4-5", "claude-haiku-4-5"}
+	}
+	config.Models.ChatMaxChars = getEnvInt("MODEL_CHAT_MAX_CHARS", 200)
+	config.Models.RoutesFile = getEnv("MODEL_ROUTES_FILE", "")
+
+	// Retry and circuit breaker configuration for AI provider calls
+	config.Retry.MaxRetries = getEnvInt("ANTHROPIC_MAX_RETRIES", 3)
+	config.Retry.BaseDelay = getEnvDuration("ANTHROPIC_RETRY_BASE_DELAY", time.Second)
+	config.Retry.MaxDelay = getEnvDuration("ANTHROPIC_RETRY_MAX_DELAY", 30*time.Second)
+	config.Retry.BreakerThreshold = getEnvInt("BREAKER_FAILURE_THRESHOLD", 5)
+	config.Retry.BreakerCooldown = getEnvDuration("BREAKER_COOLDOWN", time.Minute)
+
+	// Persona configuration
+	config.Personas.Dir = getEnv("PERSONA_DIR", "")
+	config.Personas.ReloadInterval = getEnvDuration("PERSONA_RELOAD_INTERVAL", 5*time.Second)
+
+	// Extended thinking configuration
+	config.Thinking.BudgetTokens = getEnvInt("THINKING_BUDGET_TOKENS", 0)
+	config.Thinking.Reveal = getEnv("THINKING_REVEAL", "off")
+	switch config.Thinking.Reveal {
+	case "off", "spoiler", "message":
+	default:
+		return nil, fmt.Errorf("THINKING_REVEAL must be off, spoiler or message, got %q", config.Thinking.Reveal)
+	}
+
+	// Streaming configuration
+	config.Streaming.Enabled = getEnvBool("STREAMING_ENABLED", true)
+	config.Streaming.EditInterval = getEnvDuration("STREAMING_EDIT_INTERVAL", 1200*time.Millisecond)
+
+	// Conversation store configuration
+	config.Conversation.StorePath = getEnv("CONVERSATION_STORE_PATH", "data/conversations.db")
+
+	// Context window configuration
+	config.Context.MaxTokens = getEnvInt("CONTEXT_MAX_TOKENS", 30000)
+	config.Context.SummaryModel = getEnv("CONTEXT_SUMMARY_MODEL", config.Models.FastModel)
+
+	// Attachment configuration
+	config.Attachments.MaxImages = getEnvInt("ATTACHMENT_MAX_IMAGES", 4)
+	config.Attachments.MaxImageBytes = getEnvInt("ATTACHMENT_MAX_IMAGE_BYTES", 5*1024*1024)
+	config.Attachments.MaxTextBytes = getEnvInt("ATTACHMENT_MAX_TEXT_BYTES", 64*1024)
+	config.Attachments.MaxPDFBytes = getEnvInt("ATTACHMENT_MAX_PDF_BYTES", 10*1024*1024)
+
+	// Tool configuration
+	config.Tools.PolicyFile = getEnv("TOOL_POLICY_FILE", "")
+	config.ServerTools.WebSearchMaxUses = getEnvInt("WEB_SEARCH_MAX_USES", 5)
+
+	// Weather provider configuration (Open-Meteo)
+	config.Weather.GeocodingURL = getEnv("WEATHER_GEOCODING_URL", "https://geocoding-api.open-meteo.com")
+	config.Weather.ForecastURL = getEnv("WEATHER_FORECAST_URL", "https://api.open-meteo.com")
+	config.Weather.CacheTTL = getEnvDuration("WEATHER_CACHE_TTL", 10*time.Minute)
+
+	// Web search configuration (SearxNG)
+	config.Search.URL = getEnv("SEARCH_URL", "")
+	config.Search.MaxResults = getEnvInt("SEARCH_MAX_RESULTS", 5)
+
+	// fetch_url configuration
+	config.Fetch.AllowedHosts = getEnvList("FETCH_ALLOWED_HOSTS")
+	config.Fetch.DeniedHosts = getEnvList("FETCH_DENIED_HOSTS")
+	config.Fetch.MaxBytes = getEnvInt("FETCH_MAX_BYTES", 2*1024*1024)
+	config.Fetch.Timeout = getEnvDuration("FETCH_TIMEOUT", 15*time.Second)
+	config.Fetch.MaxTokens = getEnvInt("FETCH_MAX_TOKENS", 4000)
+
+	// MCP configuration
+	config.MCP.ServersFile = getEnv("MCP_SERVERS_FILE", "")
+
+	// Per-request budget configuration (0 disables a limit)
+	config.Budget.MaxToolRounds = getEnvInt("BUDGET_MAX_TOOL_ROUNDS", 8)
+	config.Budget.MaxToolErrors = getEnvInt("BUDGET_MAX_TOOL_ERRORS", 3)
+	config.Budget.MaxInputTokens = getEnvInt("BUDGET_MAX_INPUT_TOKENS", 200000)
+	config.Budget.MaxOutputTokens = getEnvInt("BUDGET_MAX_OUTPUT_TOKENS", 8000)
+	config.Budget.Timeout = getEnvDuration("BUDGET_TIMEOUT", 2*time.Minute)
+
+	// Usage accounting and quota configuration (limits in USD, 0 disables a limit)
+	config.Usage.StorePath = getEnv("USAGE_STORE_PATH", "data/usage.db")
+	config.Usage.PricingFile = getEnv("USAGE_PRICING_FILE", "")
+	config.Usage.GuildDailyLimit = getEnvFloat("QUOTA_GUILD_DAILY_USD", 0)
+	config.Usage.GuildMonthlyLimit = getEnvFloat("QUOTA_GUILD_MONTHLY_USD", 0)
+	config.Usage.UserDailyLimit = getEnvFloat("QUOTA_USER_DAILY_USD", 0)
+	config.Usage.UserMonthlyLimit = getEnvFloat("QUOTA_USER_MONTHLY_USD", 0)
 
 	// Server configuration
 	config.Server.Port = getEnv("SERVER_PORT", "8080")
@@ -89,6 +285,26 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+// getEnvFloat gets an environment variable as a float with a fallback default
+func getEnvFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return fallback
+}
+
+// getEnvBool gets an environment variable as a boolean with a fallback default
+func getEnvBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return fallback
+}
+
 // getEnvDuration gets an environment variable as a duration with a fallback default
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
@@ -98,3 +314,14 @@ func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	}
 	return fallback
 }
+
+// getEnvList gets an environment variable as a comma-separated list, empty when unset
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,216 @@
+package conversation
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	conversationsBucket = []byte("conversations")
+	messagesBucket      = []byte("messages")
+	lastMessageIDKey    = []byte("last_message_id")
+	summaryKey          = []byte("summary")
+)
+
+// BoltStore is a Store backed by an embedded bbolt database file.
+//
+// Each conversation is a nested bucket holding its metadata and a "messages"
+// bucket whose keys are big-endian sequence numbers, so appends never rewrite
+// the existing history.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) the database file at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create conversation store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open conversation store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(conversationsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize conversation store: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Load returns the conversation for key, or an empty conversation if none exists
+func (s *BoltStore) Load(key string) (*Conversation, error) {
+	conv := &Conversation{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(conversationsBucket).Bucket([]byte(key))
+		if bucket == nil {
+			return nil
+		}
+
+		conv.LastMessageID = string(bucket.Get(lastMessageIDKey))
+		conv.Summary = string(bucket.Get(summaryKey))
+
+		messages := bucket.Bucket(messagesBucket)
+		if messages == nil {
+			return nil
+		}
+		return messages.ForEach(func(_, value []byte) error {
+			message, err := decodeMessage(value)
+			if err != nil {
+				return fmt.Errorf("failed to decode stored message: %w", err)
+			}
+			conv.Messages = append(conv.Messages, message)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversation %s: %w", key, err)
+	}
+
+	return conv, nil
+}
+
+// Append adds messages to the end of the conversation for key
+func (s *BoltStore) Append(key string, messages ...anthropic.MessageParam) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := s.conversationBucket(tx, key)
+		if err != nil {
+			return err
+		}
+		bucket, err = bucket.CreateBucketIfNotExists(messagesBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			value, err := json.Marshal(message)
+			if err != nil {
+				return fmt.Errorf("failed to encode message: %w", err)
+			}
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(sequenceKey(seq), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append to conversation %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// MarkSeen records the newest Discord message included in the conversation for key
+func (s *BoltStore) MarkSeen(key, messageID string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := s.conversationBucket(tx, key)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(lastMessageIDKey, []byte(messageID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update conversation %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// Compact replaces the oldest count messages of the conversation for key with summary
+func (s *BoltStore) Compact(key, summary string, count int) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := s.conversationBucket(tx, key)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(summaryKey, []byte(summary)); err != nil {
+			return err
+		}
+
+		messages := bucket.Bucket(messagesBucket)
+		if messages == nil {
+			return nil
+		}
+		// Collect the keys first: deleting while iterating a cursor skips entries
+		var keys [][]byte
+		cursor := messages.Cursor()
+		for k, _ := cursor.First(); k != nil && len(keys) < count; k, _ = cursor.Next() {
+			keys = append(keys, slices.Clone(k))
+		}
+		for _, k := range keys {
+			if err := messages.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compact conversation %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying database file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// conversationBucket returns the bucket for key, creating it if needed
+func (s *BoltStore) conversationBucket(tx *bolt.Tx, key string) (*bolt.Bucket, error) {
+	return tx.Bucket(conversationsBucket).CreateBucketIfNotExists([]byte(key))
+}
+
+// decodeMessage decodes a stored message. The SDK drops the whole content of a message if
+// one block is of a type it doesn't model, such as a code execution result, so blocks are
+// decoded one at a time and those it can't decode are kept as raw JSON.
+func decodeMessage(value []byte) (anthropic.MessageParam, error) {
+	var stored struct {
+		Role    anthropic.MessageParamRole `json:"role"`
+		Content []json.RawMessage          `json:"content"`
+	}
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return anthropic.MessageParam{}, err
+	}
+
+	message := anthropic.MessageParam{Role: stored.Role}
+	for _, raw := range stored.Content {
+		var block anthropic.ContentBlockParamUnion
+		if err := block.UnmarshalJSON(raw); err != nil {
+			block = param.Override[anthropic.ContentBlockParamUnion](raw)
+		}
+		message.Content = append(message.Content, block)
+	}
+	return message, nil
+}
+
+// sequenceKey encodes a sequence number so keys sort in insertion order
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -0,0 +1,47 @@
+package conversation
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
+)
+
+func TestBoltStoreKeepsUnmodelledBlocks(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "conversations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	result := `{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"code_execution_result","stdout":"4\n","stderr":"","return_code":0,"content":[]}}`
+	message := anthropic.NewAssistantMessage(
+		anthropic.NewTextBlock("Let me check."),
+		param.Override[anthropic.ContentBlockParamUnion](json.RawMessage(result)),
+		anthropic.NewTextBlock("It's 4."),
+	)
+	want, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Append("channel", message); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := store.Load("channel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conv.Messages) != 1 {
+		t.Fatalf("loaded %d messages, want 1", len(conv.Messages))
+	}
+	got, err := json.Marshal(conv.Messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("loaded message = %s, want %s", got, want)
+	}
+}
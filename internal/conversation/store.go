@@ -0,0 +1,30 @@
+package conversation
+
+import (
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Conversation is the persisted history of a single channel or thread
+type Conversation struct {
+	// Messages holds the full message history, including tool_use and tool_result blocks
+	Messages []anthropic.MessageParam
+	// LastMessageID is the ID of the newest Discord message already added to Messages
+	LastMessageID string
+	// Summary is a running summary of older messages that were compacted out of Messages
+	Summary string
+}
+
+// Store persists conversation histories keyed by channel or thread ID.
+// Discord threads are channels with their own IDs, so each thread gets its own history.
+type Store interface {
+	// Load returns the conversation for key, or an empty conversation if none exists
+	Load(key string) (*Conversation, error)
+	// Append adds messages to the end of the conversation for key
+	Append(key string, messages ...anthropic.MessageParam) error
+	// MarkSeen records the newest Discord message included in the conversation for key
+	MarkSeen(key, messageID string) error
+	// Compact replaces the oldest count messages of the conversation for key with summary
+	Compact(key, summary string, count int) error
+	// Close releases any resources held by the store
+	Close() error
+}
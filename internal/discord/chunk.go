@@ -0,0 +1,127 @@
+package discord
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is the maximum number of characters Discord allows in a single message
+const MaxMessageLength = 2000
+
+// fence is the shortest backtick fence that opens or closes a markdown code block
+const fence = "```"
+
+// SplitMessage splits content into messages of at most limit characters, breaking it
+// between paragraphs or lines where possible. A code block split across messages is closed
+// at the end of one and reopened with the same language at the start of the next.
+func SplitMessage(content string, limit int) []string {
+	var chunks []string
+	for strings.TrimSpace(content) != "" {
+		var chunk string
+		chunk, content = SplitFirst(content, limit)
+		if strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// SplitFirst splits off the first message of at most limit characters from content,
+// returning it and the rest, which starts by reopening any code block the split lands in
+func SplitFirst(content string, limit int) (string, string) {
+	if utf8.RuneCountInString(content) <= limit {
+		return content, ""
+	}
+
+	// Leave room to close a code block after the split, growing it if the block turns
+	// out to be opened by a longer fence
+	reserve := len(fence) + 1
+	for {
+		window := string([]rune(content)[:limit-reserve])
+		cut := strings.LastIndex(window, "\n\n")
+		if cut < len(window)/2 {
+			// A paragraph break early on would leave a short message; any line break will do
+			cut = strings.LastIndex(window, "\n")
+		}
+		if cut <= 0 {
+			cut = strings.LastIndex(window, " ")
+		}
+		if cut <= 0 {
+			cut = len(window)
+		}
+		head, tail := content[:cut], content[cut:]
+
+		marker, lang, fenceLine, open := openFenceAt(head)
+		if !open {
+			return strings.TrimRight(head, " \n"), strings.TrimLeft(tail, " \n")
+		}
+		if need := len(marker) + 1; need > reserve && need < limit {
+			reserve = need
+			continue
+		}
+		body := len(window)
+		if nl := strings.IndexByte(content[fenceLine:], '\n'); nl >= 0 {
+			body = fenceLine + nl + 1
+		}
+		if body >= len(window) {
+			// The fence line alone fills the message, so there's no code to carry over
+			return window, content[len(window):]
+		}
+		if cut <= body {
+			// Splitting before any of the block's code would reopen it with the same text
+			// forever, so cut within the code instead, at a space if there is one
+			head, tail = window, content[len(window):]
+			if space := strings.LastIndex(window[body:], " "); space > 0 {
+				head, tail = content[:body+space], content[body+space+1:]
+			}
+		}
+		// Indentation matters inside a code block, so only the line break is dropped
+		head = strings.TrimRight(head, "\n") + "\n" + marker
+		tail = marker + lang + "\n" + strings.TrimPrefix(tail, "\n")
+		return head, tail
+	}
+}
+
+// openFence reports whether text ends inside a code block, returning the fence that
+// opened it and the block's language. As in CommonMark, a block opened with backticks or
+// tildes is only closed by a bare fence of the same character that is at least as long,
+// so a shorter fence nested inside it is part of its content.
+func openFence(text string) (marker, lang string, open bool) {
+	marker, lang, _, open = openFenceAt(text)
+	return marker, lang, open
+}
+
+// openFenceAt is openFence that also returns where the open block's fence line starts
+func openFenceAt(text string) (marker, lang string, start int, open bool) {
+	offset := 0
+	for _, line := range strings.Split(text, "\n") {
+		lineStart := offset
+		offset += len(line) + 1
+		run, info, ok := parseFence(line)
+		if !ok {
+			continue
+		}
+		if !open {
+			marker, lang, start, open = run, info, lineStart, true
+		} else if run[0] == marker[0] && len(run) >= len(marker) && info == "" {
+			marker, lang, start, open = "", "", 0, false
+		}
+	}
+	return marker, lang, start, open
+}
+
+// parseFence splits a code fence line such as "````go" into its run of backticks or tildes
+// and its info string
+func parseFence(line string) (run, info string, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, fence) && !strings.HasPrefix(line, "~~~") {
+		return "", "", false
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	run, info = line[:n], strings.TrimSpace(line[n:])
+	if run[0] == '`' && strings.Contains(info, "`") {
+		// Inline code such as ```x``` neither opens nor closes a block
+		return "", "", false
+	}
+	return run, info, true
+}
@@ -0,0 +1,212 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSplitFirst(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		limit    int
+		wantHead string
+		wantTail string
+	}{
+		{
+			name:     "fits",
+			content:  "hello world",
+			limit:    20,
+			wantHead: "hello world",
+		},
+		{
+			name:     "paragraph break",
+			content:  "first paragraph\n\nsecond paragraph",
+			limit:    25,
+			wantHead: "first paragraph",
+			wantTail: "second paragraph",
+		},
+		{
+			name:     "early paragraph break uses a later line break",
+			content:  "a\n\nsome longer line\nand the rest of it",
+			limit:    28,
+			wantHead: "a\n\nsome longer line",
+			wantTail: "and the rest of it",
+		},
+		{
+			name:     "word break",
+			content:  "one two three four five",
+			limit:    16,
+			wantHead: "one two",
+			wantTail: "three four five",
+		},
+		{
+			name:     "code block reopened with its language",
+			content:  "```go\nline one\nline two\nline three\n```",
+			limit:    30,
+			wantHead: "```go\nline one\nline two\n```",
+			wantTail: "```go\nline three\n```",
+		},
+		{
+			name:     "closed code block",
+			content:  "```\ncode\n```\ntext after the block",
+			limit:    28,
+			wantHead: "```\ncode\n```",
+			wantTail: "text after the block",
+		},
+		{
+			name:     "inline triple backticks",
+			content:  "use ```x``` here\nand more text follows",
+			limit:    24,
+			wantHead: "use ```x``` here",
+			wantTail: "and more text follows",
+		},
+		{
+			name:     "nested shorter fence doesn't close the block",
+			content:  "````md\n```go\nx\n```\nmore\ntext\n````",
+			limit:    32,
+			wantHead: "````md\n```go\nx\n```\nmore\n````",
+			wantTail: "````md\ntext\n````",
+		},
+		{
+			name:     "tilde fence",
+			content:  "~~~\n```\nstill code\nend\n~~~",
+			limit:    24,
+			wantHead: "~~~\n```\nstill code\n~~~",
+			wantTail: "~~~\nend\n~~~",
+		},
+		{
+			name:     "code line longer than the limit",
+			content:  "```go\n" + strings.Repeat("a", 3000),
+			limit:    2000,
+			wantHead: "```go\n" + strings.Repeat("a", 1990) + "\n```",
+			wantTail: "```go\n" + strings.Repeat("a", 1010),
+		},
+		{
+			name:     "long code line broken at a space",
+			content:  "```\nxxxxxxxxxxxxxxxxxxxx yyyyyyyyyy",
+			limit:    30,
+			wantHead: "```\nxxxxxxxxxxxxxxxxxxxx\n```",
+			wantTail: "```\nyyyyyyyyyy",
+		},
+		{
+			name:     "fence line longer than the limit",
+			content:  "```" + strings.Repeat("x", 30),
+			limit:    20,
+			wantHead: "```" + strings.Repeat("x", 13),
+			wantTail: strings.Repeat("x", 17),
+		},
+		{
+			name:     "multibyte characters",
+			content:  "ééééé ééééé",
+			limit:    9,
+			wantHead: "ééééé",
+			wantTail: "ééééé",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, tail := SplitFirst(tt.content, tt.limit)
+			if head != tt.wantHead || tail != tt.wantTail {
+				t.Errorf("SplitFirst(%q, %d) = %q, %q, want %q, %q",
+					tt.content, tt.limit, head, tail, tt.wantHead, tt.wantTail)
+			}
+			if n := utf8.RuneCountInString(head); n > tt.limit {
+				t.Errorf("head is %d characters, over the limit of %d", n, tt.limit)
+			}
+		})
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("Here is the code:\n\n````markdown\n")
+	for i := range 60 {
+		if i%10 == 0 {
+			b.WriteString("```go\n")
+		}
+		b.WriteString("    fmt.Println(\"line\")\n")
+		if i%10 == 9 {
+			b.WriteString("```\n")
+		}
+	}
+	b.WriteString("````\n\nThat's all.")
+	content := b.String()
+
+	chunks := SplitMessage(content, 300)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > 300 {
+			t.Errorf("chunk %d is %d characters", i, n)
+		}
+		if _, _, open := openFence(chunk); open {
+			t.Errorf("chunk %d leaves a code block open:\n%s", i, chunk)
+		}
+		if i > 0 && !strings.HasPrefix(chunk, "````markdown\n") && i < len(chunks)-1 {
+			t.Errorf("chunk %d doesn't reopen the outer block:\n%s", i, chunk)
+		}
+	}
+	if last := chunks[len(chunks)-1]; !strings.HasSuffix(last, "That's all.") {
+		t.Errorf("last chunk = %q", last)
+	}
+}
+
+func TestSplitMessageLongCodeLine(t *testing.T) {
+	content := "```go\n" + strings.Repeat("a", 5000)
+
+	done := make(chan []string, 1)
+	go func() { done <- SplitMessage(content, MaxMessageLength) }()
+
+	select {
+	case chunks := <-done:
+		if len(chunks) != 3 {
+			t.Fatalf("got %d chunks, want 3", len(chunks))
+		}
+		total := 0
+		for i, chunk := range chunks {
+			if n := utf8.RuneCountInString(chunk); n > MaxMessageLength {
+				t.Errorf("chunk %d is %d characters", i, n)
+			}
+			if !strings.HasPrefix(chunk, "```go\n") {
+				t.Errorf("chunk %d doesn't reopen the block: %.20q", i, chunk)
+			}
+			total += strings.Count(chunk, "a")
+		}
+		if total != 5000 {
+			t.Errorf("chunks hold %d characters of code, want 5000", total)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SplitMessage didn't finish")
+	}
+}
+
+func TestOpenFence(t *testing.T) {
+	tests := []struct {
+		text       string
+		wantMarker string
+		wantLang   string
+		wantOpen   bool
+	}{
+		{text: "no code", wantOpen: false},
+		{text: "```py\nx = 1", wantMarker: "```", wantLang: "py", wantOpen: true},
+		{text: "```py\nx = 1\n```", wantOpen: false},
+		{text: "```\nx\n`````", wantOpen: false},
+		{text: "````\n```\nx\n```", wantMarker: "````", wantOpen: true},
+		{text: "```\n~~~\nx", wantMarker: "```", wantOpen: true},
+		{text: "  ~~~~ sh\necho\n~~~", wantMarker: "~~~~", wantLang: "sh", wantOpen: true},
+		{text: "```\n``` not a close", wantMarker: "```", wantOpen: true},
+	}
+
+	for _, tt := range tests {
+		marker, lang, open := openFence(tt.text)
+		if marker != tt.wantMarker || lang != tt.wantLang || open != tt.wantOpen {
+			t.Errorf("openFence(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.text, marker, lang, open, tt.wantMarker, tt.wantLang, tt.wantOpen)
+		}
+	}
+}
@@ -3,27 +3,39 @@ package discord
 import (
 	"context"
 	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/charmbracelet/log"
 )
 
+// attachmentName is the file name long messages are attached as
+const attachmentName = "response.md"
+
+// attachmentNotice is the message posted along with a long message's attachment
+const attachmentNotice = "-# 📄 This reply was too long for a message, so it's attached as a file."
+
 // Client wraps the Discord session and provides additional functionality
 type Client struct {
-	session *discordgo.Session
-	logger  *log.Logger
+	session         *discordgo.Session
+	logger          *log.Logger
+	attachThreshold int // characters past which messages are attached as a file, 0 for never
 }
 
-// NewClient creates a new Discord client
-func NewClient(token string, logger *log.Logger) (*Client, error) {
+// NewClient creates a new Discord client. Messages too long for Discord are split across
+// several, or attached as a markdown file once longer than attachThreshold characters
+// (0 always splits).
+func NewClient(token string, attachThreshold int, logger *log.Logger) (*Client, error) {
 	session, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Discord session: %w", err)
 	}
 
 	client := &Client{
-		session: session,
-		logger:  logger,
+		session:         session,
+		logger:          logger,
+		attachThreshold: attachThreshold,
 	}
 
 	// Set up event handlers
@@ -55,6 +67,46 @@ func (c *Client) Session() *discordgo.Session {
 	return c.session
 }
 
+// UserID returns the bot's own user ID, or an empty string before the session is ready
+func (c *Client) UserID() string {
+	if c.session.State == nil || c.session.State.User == nil {
+		return ""
+	}
+	return c.session.State.User.ID
+}
+
+// UserName returns the bot's own username, or an empty string before the session is ready
+func (c *Client) UserName() string {
+	if c.session.State == nil || c.session.State.User == nil {
+		return ""
+	}
+	return c.session.State.User.Username
+}
+
+// Channel returns a channel, from the session's cache when it's there
+func (c *Client) Channel(channelID string) (*discordgo.Channel, error) {
+	if channel, err := c.session.State.Channel(channelID); err == nil {
+		return channel, nil
+	}
+	channel, err := c.session.Channel(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch channel: %w", err)
+	}
+	return channel, nil
+}
+
+// Guild returns a guild, from the session's cache when it's there
+func (c *Client) Guild(guildID string) (*discordgo.Guild, error) {
+	if guild, err := c.session.State.Guild(guildID); err == nil {
+		return guild, nil
+	}
+	guild, err := c.session.Guild(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch guild: %w", err)
+	}
+	return guild, nil
+}
+
 // setupEventHandlers sets up the basic event handlers
 func (c *Client) setupEventHandlers() {
 	// Ready event
@@ -96,11 +148,72 @@ func (c *Client) SetActivity(activityType, activity string) error {
 	return c.session.UpdateGameStatus(int(discordActivityType), activity)
 }
 
-// SendMessage sends a message to a channel
+// SetNotice marks the bot idle with a notice as its custom status, for telling users
+// something is wrong. SetActivity restores the normal status.
+func (c *Client) SetNotice(notice string) error {
+	return c.session.UpdateStatusComplex(discordgo.UpdateStatusData{
+		Status: string(discordgo.StatusIdle),
+		Activities: []*discordgo.Activity{{
+			Name:  "Custom Status",
+			Type:  discordgo.ActivityTypeCustom,
+			State: notice,
+		}},
+	})
+}
+
+// SendMessage sends a message to a channel, split across several if it's too long
 func (c *Client) SendMessage(channelID, content string) error {
-	_, err := c.session.ChannelMessageSend(channelID, content)
+	_, err := c.PostMessage(channelID, content)
+	return err
+}
+
+// PostMessage sends a message to a channel and returns the created message. Content too long
+// for one message is split across several, returning the last, or attached as a file.
+func (c *Client) PostMessage(channelID, content string) (*discordgo.Message, error) {
+	length := utf8.RuneCountInString(content)
+	if c.attachThreshold > 0 && length > MaxMessageLength && length > c.attachThreshold {
+		msg, err := c.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content: attachmentNotice,
+			Files: []*discordgo.File{{
+				Name:        attachmentName,
+				ContentType: "text/markdown",
+				Reader:      strings.NewReader(content),
+			}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to send message: %w", err)
+		}
+		return msg, nil
+	}
+
+	chunks := SplitMessage(content, MaxMessageLength)
+	if len(chunks) == 0 {
+		// Let Discord reject the empty message
+		chunks = []string{content}
+	}
+	var msg *discordgo.Message
+	for _, chunk := range chunks {
+		var err error
+		if msg, err = c.session.ChannelMessageSend(channelID, chunk); err != nil {
+			return nil, fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+	return msg, nil
+}
+
+// EditMessage replaces the content of a previously sent message
+func (c *Client) EditMessage(channelID, messageID, content string) error {
+	_, err := c.session.ChannelMessageEdit(channelID, messageID, content)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return fmt.Errorf("failed to edit message: %w", err)
+	}
+	return nil
+}
+
+// DeleteMessage deletes a message from a channel
+func (c *Client) DeleteMessage(channelID, messageID string) error {
+	if err := c.session.ChannelMessageDelete(channelID, messageID); err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
 	}
 	return nil
 }
@@ -122,3 +235,12 @@ func (c *Client) GetRecentMessages(channelID string, limit int) ([]*discordgo.Me
 	}
 	return messages, nil
 }
+
+// GetMessagesBefore fetches up to limit messages sent before beforeID, newest first
+func (c *Client) GetMessagesBefore(channelID, beforeID string, limit int) ([]*discordgo.Message, error) {
+	messages, err := c.session.ChannelMessages(channelID, limit, beforeID, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch message history: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,289 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/charmbracelet/log"
+)
+
+// protocolVersion is the MCP protocol revision this client speaks
+const protocolVersion = "2025-06-18"
+
+// errClosed is returned for calls made on, or interrupted by, a closed connection
+var errClosed = errors.New("connection closed")
+
+// transport carries JSON-RPC messages to and from an MCP server
+type transport interface {
+	// send delivers a message to the server
+	send(ctx context.Context, msg *rpcMessage) error
+	// incoming returns the channel of messages from the server; it is closed when the connection ends
+	incoming() <-chan *rpcMessage
+	// close shuts the connection down
+	close() error
+}
+
+// Client is a connection to a single MCP server
+type Client struct {
+	name      string
+	transport transport
+	logger    *log.Logger
+	onNotify  func(method string)
+
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[string]chan *rpcMessage
+	done    chan struct{}
+}
+
+// newClient wraps a transport and starts dispatching its messages
+func newClient(name string, t transport, logger *log.Logger, onNotify func(method string)) *Client {
+	c := &Client{
+		name:      name,
+		transport: t,
+		logger:    logger,
+		onNotify:  onNotify,
+		pending:   make(map[string]chan *rpcMessage),
+		done:      make(chan struct{}),
+	}
+	go c.dispatch()
+	return c
+}
+
+// Done returns a channel that is closed when the connection to the server ends
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close closes the connection to the server
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+// dispatch routes incoming messages to waiting calls until the transport closes
+func (c *Client) dispatch() {
+	for msg := range c.transport.incoming() {
+		switch {
+		case msg.isResponse():
+			c.mu.Lock()
+			ch, ok := c.pending[string(msg.ID)]
+			delete(c.pending, string(msg.ID))
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		case msg.isRequest():
+			go c.answer(msg)
+		default:
+			if c.onNotify != nil {
+				c.onNotify(msg.Method)
+			}
+		}
+	}
+
+	// Mark the client done and fail any calls still waiting for a response
+	c.mu.Lock()
+	close(c.done)
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+// answer responds to a request from the server. Only ping is supported.
+func (c *Client) answer(req *rpcMessage) {
+	resp := &rpcMessage{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+
+	if err := c.transport.send(context.Background(), resp); err != nil {
+		c.logger.Warn("failed to answer MCP server request", "server", c.name, "method", req.Method, "error", err)
+	}
+}
+
+// call sends a request and decodes the result into result
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	msg := &rpcMessage{JSONRPC: "2.0", ID: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s params: %w", method, err)
+		}
+		msg.Params = data
+	}
+
+	ch := make(chan *rpcMessage, 1)
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return errClosed
+	default:
+	}
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+
+	if err := c.transport.send(ctx, msg); err != nil {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return errClosed
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("failed to decode %s result: %w", method, err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+		c.notify(context.Background(), "notifications/cancelled", map[string]any{"requestId": id, "reason": ctx.Err().Error()})
+		return ctx.Err()
+	}
+}
+
+// notify sends a notification, which has no response
+func (c *Client) notify(ctx context.Context, method string, params any) error {
+	msg := &rpcMessage{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s params: %w", method, err)
+		}
+		msg.Params = data
+	}
+	return c.transport.send(ctx, msg)
+}
+
+// initialize performs the MCP handshake
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "discord-assist", "version": "1.0.0"},
+	}
+
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("initialize failed: %w", err)
+	}
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
+		return fmt.Errorf("initialize failed: %w", err)
+	}
+
+	c.logger.Info("connected to MCP server",
+		"server", c.name,
+		"name", result.ServerInfo.Name,
+		"version", result.ServerInfo.Version,
+		"protocol", result.ProtocolVersion,
+	)
+	return nil
+}
+
+// ToolInfo describes a tool exposed by an MCP server
+type ToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// ListTools returns every tool the server exposes, following pagination
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var tools []ToolInfo
+	cursor := ""
+	for {
+		var params map[string]any
+		if cursor != "" {
+			params = map[string]any{"cursor": cursor}
+		}
+
+		var result struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, fmt.Errorf("failed to list tools: %w", err)
+		}
+		tools = append(tools, result.Tools...)
+
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// contentItem is one item of a tool call result
+type contentItem struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	MimeType string `json:"mimeType"`
+	Resource *struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"resource"`
+}
+
+// CallTool calls a tool and renders its result as text. A result the server flags as an
+// error is returned as an error carrying the rendered text.
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (string, error) {
+	params := map[string]any{"name": name, "arguments": arguments}
+
+	var result struct {
+		Content           []contentItem   `json:"content"`
+		StructuredContent json.RawMessage `json:"structuredContent"`
+		IsError           bool            `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", params, &result); err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, item := range result.Content {
+		switch {
+		case item.Type == "text":
+			parts = append(parts, item.Text)
+		case item.Type == "resource" && item.Resource != nil && item.Resource.Text != "":
+			parts = append(parts, fmt.Sprintf("Resource %s:\n%s", item.Resource.URI, item.Resource.Text))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", item.Type))
+		}
+	}
+	if len(parts) == 0 && len(result.StructuredContent) > 0 {
+		parts = append(parts, string(result.StructuredContent))
+	}
+	text := strings.Join(parts, "\n")
+
+	if result.IsError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
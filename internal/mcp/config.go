@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ServerConfig describes how to reach a single MCP server. Set Command to launch the server
+// as a subprocess speaking stdio, or URL to connect over streamable HTTP.
+type ServerConfig struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+
+	// ToolTimeout bounds each tool call, e.g. "60s"; empty uses the registry default
+	ToolTimeout string `json:"toolTimeout"`
+}
+
+// configFile is the layout of the MCP servers file, matching the common "mcpServers" format
+type configFile struct {
+	Servers map[string]ServerConfig `json:"mcpServers"`
+}
+
+// LoadConfig reads MCP server definitions from a JSON file. Environment variables in env
+// values and headers are expanded, so secrets can stay out of the file. An empty path
+// returns no servers.
+func LoadConfig(filename string) (map[string]ServerConfig, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MCP servers file: %w", err)
+	}
+
+	var file configFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse MCP servers file: %w", err)
+	}
+
+	for name, server := range file.Servers {
+		if (server.Command == "") == (server.URL == "") {
+			return nil, fmt.Errorf("MCP server %s must set exactly one of command or url", name)
+		}
+		if server.ToolTimeout != "" {
+			if _, err := time.ParseDuration(server.ToolTimeout); err != nil {
+				return nil, fmt.Errorf("MCP server %s has an invalid toolTimeout: %w", name, err)
+			}
+		}
+		for key, value := range server.Env {
+			server.Env[key] = os.ExpandEnv(value)
+		}
+		for key, value := range server.Headers {
+			server.Headers[key] = os.ExpandEnv(value)
+		}
+	}
+
+	return file.Servers, nil
+}
+
+// toolTimeout returns the configured tool call timeout, or zero for the registry default
+func (c ServerConfig) toolTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(c.ToolTimeout)
+	return timeout
+}
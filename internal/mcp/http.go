@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTransport talks to an MCP server over the streamable HTTP transport. Each message is
+// POSTed to the endpoint, and responses come back as JSON or as a server-sent event stream.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+	closed    bool
+	messages  chan *rpcMessage
+}
+
+// newHTTPTransport creates a transport for the server at cfg.URL
+func newHTTPTransport(cfg ServerConfig) *httpTransport {
+	return &httpTransport{
+		url:      cfg.URL,
+		headers:  cfg.Headers,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		messages: make(chan *rpcMessage, 16),
+	}
+}
+
+// send POSTs a message and delivers any messages in the response to the incoming channel
+func (t *httpTransport) send(ctx context.Context, msg *rpcMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && t.hasSession():
+		// The server forgot our session; close so the connection is re-established
+		t.close()
+		return fmt.Errorf("session expired")
+	case resp.StatusCode == http.StatusAccepted:
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return t.readEvents(resp.Body)
+	}
+
+	var reply rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	t.deliver(&reply)
+	return nil
+}
+
+// readEvents delivers each JSON-RPC message in a server-sent event stream
+func (t *httpTransport) readEvents(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line ends the event
+			if data.Len() > 0 {
+				var msg rpcMessage
+				if err := json.Unmarshal([]byte(data.String()), &msg); err == nil {
+					t.deliver(&msg)
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if data.Len() > 0 {
+		var msg rpcMessage
+		if err := json.Unmarshal([]byte(data.String()), &msg); err == nil {
+			t.deliver(&msg)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event stream: %w", err)
+	}
+	return nil
+}
+
+// newRequest creates a request carrying the configured headers and the session ID
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("MCP-Protocol-Version", protocolVersion)
+
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	return req, nil
+}
+
+// hasSession reports whether the server assigned a session ID
+func (t *httpTransport) hasSession() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID != ""
+}
+
+// deliver queues a message for the client unless the transport is closed
+func (t *httpTransport) deliver(msg *rpcMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.messages <- msg
+	}
+}
+
+// incoming returns the channel of messages received in responses
+func (t *httpTransport) incoming() <-chan *rpcMessage {
+	return t.messages
+}
+
+// close ends the session on the server and closes the incoming channel
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.messages)
+	hadSession := t.sessionID != ""
+	t.mu.Unlock()
+
+	// Best effort: tell the server the session is over
+	if hadSession {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if req, err := t.newRequest(ctx, http.MethodDelete, nil); err == nil {
+			if resp, err := t.client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	return nil
+}
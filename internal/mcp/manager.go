@@ -0,0 +1,293 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/charmbracelet/log"
+
+	"discord-assist/internal/ai"
+)
+
+const (
+	// connectTimeout bounds starting a server and listing its tools
+	connectTimeout = 30 * time.Second
+	// minRestartDelay and maxRestartDelay bound the backoff between restarts of a crashed server
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+	// stableAfter is how long a server must stay up for its restart backoff to reset
+	stableAfter = 5 * time.Minute
+	// maxToolNameLength is the longest tool name the Anthropic API accepts
+	maxToolNameLength = 64
+)
+
+// invalidToolNameChars matches characters not allowed in Anthropic tool names
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Manager keeps configured MCP servers connected and their tools registered in a ToolRegistry.
+// Tools are registered as "mcp_<server>_<tool>", so policies can match them with patterns
+// such as "mcp_github_*".
+type Manager struct {
+	registry *ai.ToolRegistry
+	logger   *log.Logger
+	servers  []*server
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager creates a manager for the given servers
+func NewManager(configs map[string]ServerConfig, registry *ai.ToolRegistry, logger *log.Logger) *Manager {
+	m := &Manager{
+		registry: registry,
+		logger:   logger,
+	}
+
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m.servers = append(m.servers, &server{
+			name:     name,
+			config:   configs[name],
+			registry: registry,
+			logger:   logger,
+		})
+	}
+
+	return m
+}
+
+// Start connects to every server in the background, restarting any that crash until ctx
+// is cancelled or Close is called
+func (m *Manager) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, srv := range m.servers {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			srv.run(ctx)
+		}()
+	}
+}
+
+// Close disconnects from every server and unregisters their tools
+func (m *Manager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+}
+
+// server supervises the connection to a single MCP server
+type server struct {
+	name     string
+	config   ServerConfig
+	registry *ai.ToolRegistry
+	logger   *log.Logger
+
+	mu     sync.Mutex
+	client *Client
+	tools  []string // registered tool names
+}
+
+// run keeps the server connected, restarting it with exponential backoff when it fails
+func (s *server) run(ctx context.Context) {
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		err := s.serve(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > stableAfter {
+			delay = minRestartDelay
+		}
+		s.logger.Warn("MCP server disconnected, restarting", "server", s.name, "error", err, "delay", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+// serve connects to the server, registers its tools and waits for the connection to end
+func (s *server) serve(ctx context.Context) error {
+	client, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	s.mu.Lock()
+	s.client = client
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.client = nil
+		s.mu.Unlock()
+		s.unregisterTools()
+	}()
+
+	if err := s.syncTools(ctx, client); err != nil {
+		return err
+	}
+
+	select {
+	case <-client.Done():
+		return errClosed
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// connect opens the transport and performs the MCP handshake
+func (s *server) connect(ctx context.Context) (*Client, error) {
+	var t transport
+	if s.config.URL != "" {
+		t = newHTTPTransport(s.config)
+	} else {
+		stdio, err := startStdio(s.name, s.config, s.logger)
+		if err != nil {
+			return nil, err
+		}
+		t = stdio
+	}
+
+	var client *Client
+	client = newClient(s.name, t, s.logger, func(method string) {
+		if method == "notifications/tools/list_changed" {
+			go func() {
+				if err := s.syncTools(ctx, client); err != nil {
+					s.logger.Error("failed to refresh MCP tools", "server", s.name, "error", err)
+				}
+			}()
+		}
+	})
+
+	initCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	if err := client.initialize(initCtx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// syncTools lists the server's tools and replaces the ones registered for it
+func (s *server) syncTools(ctx context.Context, client *Client) error {
+	listCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	infos, err := client.ListTools(listCtx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A refresh can finish after the connection it was for has gone away
+	if s.client != client {
+		return nil
+	}
+
+	for _, name := range s.tools {
+		s.registry.Unregister(name)
+	}
+	s.tools = nil
+
+	for _, info := range infos {
+		tool, err := s.newTool(info)
+		if err != nil {
+			s.logger.Warn("skipping MCP tool", "server", s.name, "tool", info.Name, "error", err)
+			continue
+		}
+		if err := s.registry.Register(tool); err != nil {
+			s.logger.Warn("skipping MCP tool", "server", s.name, "tool", info.Name, "error", err)
+			continue
+		}
+		s.tools = append(s.tools, tool.Name)
+	}
+
+	s.logger.Info("registered MCP tools", "server", s.name, "tools", s.tools)
+	return nil
+}
+
+// unregisterTools removes all of the server's tools from the registry
+func (s *server) unregisterTools() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range s.tools {
+		s.registry.Unregister(name)
+	}
+	s.tools = nil
+}
+
+// newTool wraps an MCP tool as an ai.Tool that proxies calls to the server
+func (s *server) newTool(info ToolInfo) (*ai.Tool, error) {
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+		Required   []string       `json:"required"`
+	}
+	if len(info.InputSchema) > 0 {
+		if err := json.Unmarshal(info.InputSchema, &schema); err != nil {
+			return nil, fmt.Errorf("invalid input schema: %w", err)
+		}
+	}
+
+	toolName := info.Name
+	return &ai.Tool{
+		ToolParam: anthropic.ToolParam{
+			Type:        anthropic.ToolTypeCustom,
+			Name:        s.toolName(info.Name),
+			Description: anthropic.String(info.Description),
+			InputSchema: anthropic.ToolInputSchemaParam{
+				Type:       "object",
+				Properties: schema.Properties,
+				Required:   schema.Required,
+			},
+		},
+		Timeout: s.config.toolTimeout(),
+		Execute: func(ctx context.Context, params map[string]any) (string, error) {
+			return s.callTool(ctx, toolName, params)
+		},
+	}, nil
+}
+
+// callTool proxies a tool call to the currently connected client
+func (s *server) callTool(ctx context.Context, name string, arguments map[string]any) (string, error) {
+	s.mu.Lock()
+	client := s.client
+	s.mu.Unlock()
+
+	if client == nil {
+		return "", errors.New("MCP server " + s.name + " is not connected")
+	}
+	return client.CallTool(ctx, name, arguments)
+}
+
+// toolName builds the prefixed registry name for one of the server's tools
+func (s *server) toolName(name string) string {
+	prefixed := invalidToolNameChars.ReplaceAllString("mcp_"+s.name+"_"+name, "_")
+	if len(prefixed) > maxToolNameLength {
+		prefixed = prefixed[:maxToolNameLength]
+	}
+	return prefixed
+}
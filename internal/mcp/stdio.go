@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// stdioShutdownGrace is how long a server gets to exit after its stdin closes before it is killed
+const stdioShutdownGrace = 3 * time.Second
+
+// stdioTransport talks to an MCP server subprocess over newline-delimited JSON on stdin/stdout
+type stdioTransport struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	logger *log.Logger
+
+	writeMu    sync.Mutex
+	messages   chan *rpcMessage
+	stderrDone chan struct{} // closed once stderr has been read to the end
+	exited     chan struct{}
+	closeOnce  sync.Once
+}
+
+// startStdio launches the server process and starts reading its output
+func startStdio(name string, cfg ServerConfig, logger *log.Logger) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stderr: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", cfg.Command, err)
+	}
+
+	t := &stdioTransport{
+		name:       name,
+		cmd:        cmd,
+		stdin:      stdin,
+		logger:     logger,
+		messages:   make(chan *rpcMessage, 16),
+		stderrDone: make(chan struct{}),
+		exited:     make(chan struct{}),
+	}
+	go t.logStderr(stderr)
+	go t.read(stdout)
+
+	return t, nil
+}
+
+// read decodes messages from stdout until the process exits
+func (t *stdioTransport) read(stdout io.Reader) {
+	defer close(t.messages)
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var msg rpcMessage
+			if jsonErr := json.Unmarshal(line, &msg); jsonErr != nil {
+				t.logger.Warn("ignoring malformed MCP message", "server", t.name, "error", jsonErr)
+			} else {
+				t.messages <- &msg
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// Wait closes the pipes, so stderr has to be read to the end first
+	<-t.stderrDone
+	err := t.cmd.Wait()
+	close(t.exited)
+	t.logger.Warn("MCP server process exited", "server", t.name, "error", err)
+}
+
+// logStderr forwards the server's stderr to the debug log until the process closes it
+func (t *stdioTransport) logStderr(stderr io.Reader) {
+	defer close(t.stderrDone)
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		t.logger.Debug("MCP server stderr", "server", t.name, "line", scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.logger.Debug("MCP server stderr unreadable, discarding the rest", "server", t.name, "error", err)
+		// Keep draining so the server doesn't block writing to a full pipe
+		io.Copy(io.Discard, stderr)
+	}
+}
+
+// send writes a message as a single line on the server's stdin
+func (t *stdioTransport) send(ctx context.Context, msg *rpcMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to server: %w", err)
+	}
+	return nil
+}
+
+// incoming returns the channel of messages read from stdout
+func (t *stdioTransport) incoming() <-chan *rpcMessage {
+	return t.messages
+}
+
+// close closes stdin so the server can exit cleanly, killing it if it doesn't
+func (t *stdioTransport) close() error {
+	t.closeOnce.Do(func() {
+		t.stdin.Close()
+		select {
+		case <-t.exited:
+		case <-time.After(stdioShutdownGrace):
+			t.cmd.Process.Kill()
+			<-t.exited
+		}
+	})
+	return nil
+}
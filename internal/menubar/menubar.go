@@ -3,6 +3,7 @@ package menubar
 import (
 	"context"
 	"os"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/getlantern/systray"
@@ -12,20 +13,29 @@ import (
 type MenuBar struct {
 	logger *log.Logger
 	bot    BotController
+
+	// done is closed on exit to stop background refreshes
+	done chan struct{}
 }
 
 // BotController defines the interface for controlling the bot
 type BotController interface {
 	Start(ctx context.Context) error
 	Stop() error
+	Close() error
 	IsRunning() bool
+	APIStatus() string
 }
 
+// statusInterval is how often the API status item is refreshed
+const statusInterval = 5 * time.Second
+
 // New creates a new menu bar instance
 func New(bot BotController, logger *log.Logger) *MenuBar {
 	return &MenuBar{
 		logger: logger,
 		bot:    bot,
+		done:   make(chan struct{}),
 	}
 }
 
@@ -52,6 +62,23 @@ func (m *MenuBar) onReady() {
 	systray.AddSeparator()
 	mStart := systray.AddMenuItem("Start Bot", "Start the Discord bot")
 	mStop := systray.AddMenuItem("Stop Bot", "Stop the Discord bot")
+	systray.AddSeparator()
+	mStatus := systray.AddMenuItem(m.bot.APIStatus(), "Whether the bot can reach its AI provider")
+	mStatus.Disable()
+
+	// Keep the API status up to date
+	go func() {
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				mStatus.SetTitle(m.bot.APIStatus())
+			case <-m.done:
+				return
+			}
+		}
+	}()
 
 	// Start the bot automatically
 	go func() {
@@ -92,11 +119,10 @@ func (m *MenuBar) onReady() {
 // onExit is called when the systray is exiting
 func (m *MenuBar) onExit() {
 	m.logger.Info("menu bar exiting")
-	// Stop the bot when the menu bar exits
-	if m.bot.IsRunning() {
-		if err := m.bot.Stop(); err != nil {
-			m.logger.Error("failed to stop bot on exit", "error", err)
-		}
+	close(m.done)
+	// Stop the bot and release its files when the menu bar exits
+	if err := m.bot.Close(); err != nil {
+		m.logger.Error("failed to close bot on exit", "error", err)
 	}
 }
 
@@ -0,0 +1,146 @@
+package usage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	recordsBucket = []byte("records")
+	totalsBucket  = []byte("totals")
+)
+
+// BoltLedger is a Ledger backed by an embedded bbolt database file.
+//
+// Every record is appended to a "records" bucket, and a "totals" bucket keeps running
+// totals keyed by scope, ID and period (e.g. "guild/123/day/2006-01-02"), so quota checks
+// never have to scan the records.
+type BoltLedger struct {
+	db *bolt.DB
+}
+
+// NewBoltLedger opens (or creates) the database file at path
+func NewBoltLedger(path string) (*BoltLedger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create usage ledger directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, totalsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize usage ledger: %w", err)
+	}
+
+	return &BoltLedger{db: db}, nil
+}
+
+// Record stores a usage record and adds it to the totals of its guild and user
+func (l *BoltLedger) Record(rec Record) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+		value, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode usage record: %w", err)
+		}
+		seq, err := records.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := records.Put(sequenceKey(seq), value); err != nil {
+			return err
+		}
+
+		totals := tx.Bucket(totalsBucket)
+		for _, period := range []Period{PeriodDay, PeriodMonth} {
+			if rec.GuildID != "" {
+				if err := addTotals(totals, totalsKey(ScopeGuild, rec.GuildID, period, rec.Time), rec); err != nil {
+					return err
+				}
+			}
+			if rec.UserID != "" {
+				if err := addTotals(totals, totalsKey(ScopeUser, rec.UserID, period, rec.Time), rec); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+
+	return nil
+}
+
+// Totals returns the usage of a guild or user in the period containing at
+func (l *BoltLedger) Totals(scope Scope, id string, period Period, at time.Time) (Totals, error) {
+	var totals Totals
+	err := l.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(totalsBucket).Get(totalsKey(scope, id, period, at))
+		if value == nil {
+			return nil
+		}
+		return json.Unmarshal(value, &totals)
+	})
+	if err != nil {
+		return Totals{}, fmt.Errorf("failed to read usage totals: %w", err)
+	}
+
+	return totals, nil
+}
+
+// Close closes the underlying database file
+func (l *BoltLedger) Close() error {
+	return l.db.Close()
+}
+
+// addTotals adds a record to the totals stored under key
+func addTotals(bucket *bolt.Bucket, key []byte, rec Record) error {
+	var totals Totals
+	if value := bucket.Get(key); value != nil {
+		if err := json.Unmarshal(value, &totals); err != nil {
+			return fmt.Errorf("failed to decode usage totals: %w", err)
+		}
+	}
+	totals.add(rec)
+
+	value, err := json.Marshal(totals)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage totals: %w", err)
+	}
+	return bucket.Put(key, value)
+}
+
+// totalsKey builds the key of a scope's totals for the period containing t
+func totalsKey(scope Scope, id string, period Period, t time.Time) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%s/%s", scope, id, period, period.bucket(t)))
+}
+
+// sequenceKey encodes a sequence number so keys sort in insertion order
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -0,0 +1,115 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Price is what a model costs, in US dollars per million tokens
+type Price struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cacheWrite"`
+	CacheRead  float64 `json:"cacheRead"`
+}
+
+// webSearchCost is what Anthropic charges per web search, in US dollars
+const webSearchCost = 10.0 / 1000
+
+// defaultPrices are Anthropic's list prices, keyed by model ID prefix. The bare
+// "claude-opus-4" prefix covers Opus 4 and 4.1; later Opus models are cheaper. Claude models
+// missing from the table fall back to "claude-", priced like the most expensive model so a
+// new model can't slip past usage quotas as free.
+var defaultPrices = map[string]Price{
+	"claude-":           {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+	"claude-opus-4-6":   {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50},
+	"claude-opus-4-5":   {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50},
+	"claude-opus-4":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+	"claude-sonnet-4":   {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"claude-haiku-4":    {Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.10},
+	"claude-3-7-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"claude-3-5-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+	"claude-3-opus":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25, CacheWrite: 0.30, CacheRead: 0.03},
+}
+
+// Pricing estimates the cost of API calls from a table of model prices
+type Pricing struct {
+	prices   map[string]Price
+	prefixes []string // keys of prices, longest first
+}
+
+// LoadPricing returns the default price table, with entries from the JSON file at filename
+// (a map of model ID prefix to Price) added or overriding them. An empty path uses the defaults.
+func LoadPricing(filename string) (*Pricing, error) {
+	prices := make(map[string]Price, len(defaultPrices))
+	for model, price := range defaultPrices {
+		prices[model] = price
+	}
+
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pricing file: %w", err)
+		}
+		var overrides map[string]Price
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("failed to parse pricing file: %w", err)
+		}
+		for model, price := range overrides {
+			prices[model] = price
+		}
+	}
+
+	prefixes := make([]string, 0, len(prices))
+	for prefix := range prices {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) > len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+
+	return &Pricing{prices: prices, prefixes: prefixes}, nil
+}
+
+// Price returns the price of the model whose ID prefix matches longest
+func (p *Pricing) Price(model string) (Price, bool) {
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(model, prefix) {
+			return p.prices[prefix], true
+		}
+	}
+	return Price{}, false
+}
+
+// NewRecord builds a usage record for an API response, estimating its cost. Models missing
+// from the price table, such as local models behind an OpenAI-compatible API, cost nothing;
+// a pricing file entry for the empty prefix "" prices them all.
+func (p *Pricing) NewRecord(model string, u anthropic.Usage) Record {
+	rec := Record{
+		Model:            model,
+		InputTokens:      u.InputTokens,
+		OutputTokens:     u.OutputTokens,
+		CacheReadTokens:  u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+		WebSearches:      u.ServerToolUse.WebSearchRequests,
+	}
+
+	price, _ := p.Price(model)
+	rec.Cost = (float64(rec.InputTokens)*price.Input +
+		float64(rec.OutputTokens)*price.Output +
+		float64(rec.CacheReadTokens)*price.CacheRead +
+		float64(rec.CacheWriteTokens)*price.CacheWrite) / 1e6
+	rec.Cost += float64(rec.WebSearches) * webSearchCost
+
+	return rec
+}
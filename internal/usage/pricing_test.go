@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+func TestPricingPrice(t *testing.T) {
+	pricing, err := LoadPricing("")
+	if err != nil {
+		t.Fatalf("LoadPricing: %v", err)
+	}
+
+	tests := []struct {
+		model     string
+		wantInput float64
+		wantOK    bool
+	}{
+		{model: "claude-opus-4-5-20251101", wantInput: 5, wantOK: true},
+		{model: "claude-opus-4-6", wantInput: 5, wantOK: true},
+		{model: "claude-opus-4-1-20250805", wantInput: 15, wantOK: true},
+		{model: "claude-opus-4-0", wantInput: 15, wantOK: true},
+		{model: "claude-sonnet-4-5", wantInput: 3, wantOK: true},
+		{model: "claude-haiku-4-5-20251001", wantInput: 1, wantOK: true},
+		{model: "claude-3-5-haiku-latest", wantInput: 0.80, wantOK: true},
+		{model: "claude-mystery-5", wantInput: 15, wantOK: true},
+		{model: "gpt-4o", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		price, ok := pricing.Price(tt.model)
+		if ok != tt.wantOK || price.Input != tt.wantInput {
+			t.Errorf("Price(%q) = %+v, %v, want input %v, %v", tt.model, price, ok, tt.wantInput, tt.wantOK)
+		}
+	}
+}
+
+func TestPricingOverrides(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pricing.json")
+	overrides := `{"claude-opus-4-5": {"input": 4, "output": 20}, "my-model": {"input": 1, "output": 2}}`
+	if err := os.WriteFile(filename, []byte(overrides), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pricing, err := LoadPricing(filename)
+	if err != nil {
+		t.Fatalf("LoadPricing: %v", err)
+	}
+	for model, want := range map[string]float64{"claude-opus-4-5": 4, "my-model-v2": 1, "claude-opus-4-1": 15} {
+		if price, _ := pricing.Price(model); price.Input != want {
+			t.Errorf("Price(%q).Input = %v, want %v", model, price.Input, want)
+		}
+	}
+}
+
+func TestNewRecordCost(t *testing.T) {
+	pricing, err := LoadPricing("")
+	if err != nil {
+		t.Fatalf("LoadPricing: %v", err)
+	}
+	u := anthropic.Usage{InputTokens: 1_000_000, OutputTokens: 100_000}
+	u.ServerToolUse.WebSearchRequests = 2
+
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{model: "claude-sonnet-4-5", want: 3 + 1.5 + 0.02},
+		{model: "claude-haiku-4-5", want: 1 + 0.5 + 0.02},
+		// Unknown Claude models are charged like the most expensive one
+		{model: "claude-mystery-5", want: 15 + 7.5 + 0.02},
+		// Other unpriced models, such as local ones, only pay for web searches
+		{model: "llama3.1", want: 0.02},
+	}
+
+	for _, tt := range tests {
+		if got := pricing.NewRecord(tt.model, u).Cost; math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("NewRecord(%q).Cost = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
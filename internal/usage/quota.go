@@ -0,0 +1,65 @@
+package usage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Quotas are spending limits in US dollars; zero means no limit
+type Quotas struct {
+	GuildDaily   float64
+	GuildMonthly float64
+	UserDaily    float64
+	UserMonthly  float64
+}
+
+// QuotaExceeded describes the quota a guild or user has used up
+type QuotaExceeded struct {
+	Scope   Scope
+	Period  Period
+	Limit   float64
+	Used    float64
+	ResetAt time.Time
+}
+
+// Error implements the error interface
+func (e *QuotaExceeded) Error() string {
+	return fmt.Sprintf("%s %s quota of $%.2f exceeded ($%.2f used)", e.Scope, e.Period, e.Limit, e.Used)
+}
+
+// Check returns the first quota the guild or user has used up at time now, or nil if
+// there is room left. An empty guildID (a direct message) skips the guild quotas.
+func (q Quotas) Check(ledger Ledger, guildID, userID string, now time.Time) (*QuotaExceeded, error) {
+	checks := []struct {
+		scope  Scope
+		id     string
+		period Period
+		limit  float64
+	}{
+		{ScopeGuild, guildID, PeriodDay, q.GuildDaily},
+		{ScopeGuild, guildID, PeriodMonth, q.GuildMonthly},
+		{ScopeUser, userID, PeriodDay, q.UserDaily},
+		{ScopeUser, userID, PeriodMonth, q.UserMonthly},
+	}
+
+	for _, check := range checks {
+		if check.limit <= 0 || check.id == "" {
+			continue
+		}
+		totals, err := ledger.Totals(check.scope, check.id, check.period, now)
+		if err != nil {
+			return nil, err
+		}
+		if totals.Cost >= check.limit {
+			return &QuotaExceeded{
+				Scope:   check.scope,
+				Period:  check.period,
+				Limit:   check.limit,
+				Used:    totals.Cost,
+				ResetAt: check.period.End(now),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
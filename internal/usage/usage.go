@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"time"
+)
+
+// Record is the usage of a single API call
+type Record struct {
+	Time             time.Time `json:"time"`
+	GuildID          string    `json:"guildId,omitempty"`
+	ChannelID        string    `json:"channelId"`
+	UserID           string    `json:"userId"`
+	Model            string    `json:"model"`
+	InputTokens      int64     `json:"inputTokens"`
+	OutputTokens     int64     `json:"outputTokens"`
+	CacheReadTokens  int64     `json:"cacheReadTokens"`
+	CacheWriteTokens int64     `json:"cacheWriteTokens"`
+	WebSearches      int64     `json:"webSearches"`
+	// Cost is the estimated cost in US dollars, from the pricing table
+	Cost float64 `json:"cost"`
+}
+
+// Totals sums the usage recorded for a guild or user over a period
+type Totals struct {
+	Requests         int64   `json:"requests"`
+	InputTokens      int64   `json:"inputTokens"`
+	OutputTokens     int64   `json:"outputTokens"`
+	CacheReadTokens  int64   `json:"cacheReadTokens"`
+	CacheWriteTokens int64   `json:"cacheWriteTokens"`
+	WebSearches      int64   `json:"webSearches"`
+	Cost             float64 `json:"cost"`
+}
+
+// add adds a record to the totals
+func (t *Totals) add(rec Record) {
+	t.Requests++
+	t.InputTokens += rec.InputTokens
+	t.OutputTokens += rec.OutputTokens
+	t.CacheReadTokens += rec.CacheReadTokens
+	t.CacheWriteTokens += rec.CacheWriteTokens
+	t.WebSearches += rec.WebSearches
+	t.Cost += rec.Cost
+}
+
+// Scope is what usage totals are kept for
+type Scope string
+
+const (
+	ScopeGuild Scope = "guild"
+	ScopeUser  Scope = "user"
+)
+
+// Period is the length of time usage totals cover. Periods are calendar days and months in UTC.
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodMonth Period = "month"
+)
+
+// bucket returns the name of the period containing t, e.g. "2006-01-02" for a day
+func (p Period) bucket(t time.Time) string {
+	t = t.UTC()
+	if p == PeriodMonth {
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
+
+// End returns when the period containing t ends
+func (p Period) End(t time.Time) time.Time {
+	t = t.UTC()
+	if p == PeriodMonth {
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// Ledger records API usage and keeps running totals per guild and user
+type Ledger interface {
+	// Record stores a usage record and adds it to the totals of its guild and user
+	Record(rec Record) error
+	// Totals returns the usage of a guild or user in the period containing at
+	Totals(scope Scope, id string, period Period, at time.Time) (Totals, error)
+	// Close releases any resources held by the ledger
+	Close() error
+}